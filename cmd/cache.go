@@ -0,0 +1,88 @@
+package cmd
+
+import (
+"fmt"
+
+"github.com/OneManRepo/notion2memos/internal/cache"
+"github.com/OneManRepo/notion2memos/internal/config"
+"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the Notion response cache",
+	Long: `Page content, page metadata, databases and data sources fetched from Notion
+are cached in ~/.notion2memos/cache so that reruns only fetch pages that
+changed since the last run.`,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached Notion responses",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := openCache()
+		if err != nil {
+			return err
+		}
+		if err := c.Clear(); err != nil {
+			return err
+		}
+		fmt.Println("Cache has been cleared")
+		return nil
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the number and size of cached Notion responses",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := openCache()
+		if err != nil {
+			return err
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Cache directory: %s\n\n", c.Dir())
+		var entries int
+		var bytes int64
+		for _, s := range stats {
+			fmt.Printf("  %-14s %8d entries %10s\n", s.Kind, s.Entries, formatBytes(s.Bytes))
+			entries += s.Entries
+			bytes += s.Bytes
+		}
+		fmt.Printf("  %-14s %8d entries %10s\n", "total", entries, formatBytes(bytes))
+		return nil
+	},
+}
+
+// openCache opens the cache in its default directory
+func openCache() (*cache.Cache, error) {
+	dir, err := config.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Open(dir), nil
+}
+
+// formatBytes formats a size with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+}
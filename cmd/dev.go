@@ -0,0 +1,84 @@
+package cmd
+
+import (
+"fmt"
+"log"
+"net/http"
+
+"github.com/OneManRepo/notion2memos/internal/fakeserver"
+"github.com/spf13/cobra"
+)
+
+var (
+fakeServerAddr     string
+fakeServerFixtures string
+fakeServerRecord   string
+fakeServerVersion  string
+fakeServerLimit    int
+
+fakeServerFailDisplayTime int
+fakeServerFailDeletes     int
+fakeServerFailPages       []string
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Development tools",
+	Long:  `Tools for developing and testing notion2memos.`,
+}
+
+var fakeServerCmd = &cobra.Command{
+	Use:   "fake-server",
+	Short: "Run a local fake Notion and Memos server",
+	Long: `Serves Notion search, pages, blocks, databases and data sources from a
+fixtures directory and records the memos created and patched through the Memos
+API, so migrations can be tested end to end without network access.
+
+Point a config at it with:
+  notion_api_url: "http://<addr>/v1"
+  memos_url: "http://<addr>"
+
+The recorded calls are available at /_fake/calls and /_fake/memos.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := fakeserver.New(fakeServerFixtures)
+		if err != nil {
+			return err
+		}
+		server.SetMemosVersion(fakeServerVersion)
+		server.SetContentLengthLimit(fakeServerLimit)
+		server.FailDisplayTime(fakeServerFailDisplayTime)
+		server.FailDeletes(fakeServerFailDeletes)
+		server.FailPages(fakeServerFailPages)
+		if fakeServerRecord != "" {
+			server.RecordTo(fakeServerRecord)
+		}
+
+		fmt.Printf("Fake server listening on http://%s\n", fakeServerAddr)
+		fmt.Printf("  notion_api_url: \"http://%s/v1\"\n", fakeServerAddr)
+		fmt.Printf("  memos_url: \"http://%s\"\n", fakeServerAddr)
+
+		return http.ListenAndServe(fakeServerAddr, logRequests(server))
+	},
+}
+
+// logRequests logs every request handled by the fake server
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s\n", r.Method, r.URL.RequestURI())
+		next.ServeHTTP(w, r)
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(devCmd)
+	devCmd.AddCommand(fakeServerCmd)
+	fakeServerCmd.Flags().StringVar(&fakeServerAddr, "addr", "127.0.0.1:8787", "address to listen on")
+	fakeServerCmd.Flags().StringVar(&fakeServerFixtures, "fixtures", "", "directory with Notion fixtures")
+	fakeServerCmd.Flags().StringVar(&fakeServerRecord, "record", "", "file to write recorded Memos calls to")
+	fakeServerCmd.Flags().StringVar(&fakeServerVersion, "memos-version", fakeserver.DefaultMemosVersion, "Memos version reported by the workspace profile")
+	fakeServerCmd.Flags().IntVar(&fakeServerLimit, "content-length-limit", fakeserver.DefaultContentLengthLimit, "memo content limit in bytes reported by the memo settings and enforced")
+	fakeServerCmd.Flags().IntVar(&fakeServerFailDisplayTime, "fail-display-time", 0, "fail the first N display time patches")
+	fakeServerCmd.Flags().IntVar(&fakeServerFailDeletes, "fail-deletes", 0, "fail the first N memo deletions")
+	fakeServerCmd.Flags().StringSliceVar(&fakeServerFailPages, "fail-pages", nil, "fail creating the memos of these Notion page IDs")
+	fakeServerCmd.MarkFlagRequired("fixtures")
+}
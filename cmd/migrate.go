@@ -1,6 +1,14 @@
 package cmd
 
 import (
+"context"
+"errors"
+"fmt"
+"log"
+"os"
+"os/signal"
+"syscall"
+
 "github.com/OneManRepo/notion2memos/internal/config"
 "github.com/OneManRepo/notion2memos/internal/migrate"
 "github.com/spf13/cobra"
@@ -8,7 +16,16 @@ import (
 
 var (
 resume       bool
+update       bool
 filterTitles []string
+
+existingMode   string
+existingFilter string
+
+workers int
+
+continueOnError bool
+retryFailed     bool
 )
 
 var migrateCmd = &cobra.Command{
@@ -17,6 +34,18 @@ var migrateCmd = &cobra.Command{
 	Long: `Searches for all pages in Notion and migrates them to Memos.
 Supports filtering by exact page titles and resuming interrupted migrations.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		switch existingMode {
+		case "", migrate.ExistingSkip, migrate.ExistingLink, migrate.ExistingUpdate:
+		default:
+			return fmt.Errorf("invalid --existing %q: must be skip, link or update", existingMode)
+		}
+		if workers < 1 {
+			return fmt.Errorf("invalid --workers %d: must be at least 1", workers)
+		}
+		if retryFailed && resume {
+			return fmt.Errorf("--retry-failed and --resume can't be combined")
+		}
+
 		// Load configuration
 		cfg, err := config.Load(cfgFile)
 		if err != nil {
@@ -24,7 +53,7 @@ Supports filtering by exact page titles and resuming interrupted migrations.`,
 		}
 
 		// Create migrator
-		migrator, err := migrate.NewMigrator(cfg, dryRun)
+		migrator, err := migrate.NewMigrator(cfg, dryRun, !noCache)
 		if err != nil {
 			return err
 		}
@@ -33,14 +62,57 @@ Supports filtering by exact page titles and resuming interrupted migrations.`,
 		opts := migrate.MigrateOptions{
 			Resume:       resume,
 			FilterTitles: filterTitles,
+			Update:       update,
+
+			Existing:       existingMode,
+			ExistingFilter: existingFilter,
+
+			Workers: workers,
+
+			ContinueOnError: continueOnError,
+			RetryFailed:     retryFailed,
 		}
 
-		return migrator.Migrate(opts)
+		ctx, stop := interruptContext()
+		defer stop()
+
+		err = migrator.Migrate(ctx, opts)
+		if errors.Is(err, migrate.ErrPartialSuccess) {
+			// The failed pages were listed already
+			cmd.SilenceUsage = true
+		}
+		return err
 	},
 }
 
+// interruptContext returns a context cancelled on Ctrl-C or SIGTERM; the
+// current pages are finished or abandoned and the state is saved. A second
+// signal exits immediately.
+func interruptContext() (context.Context, func()) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+			log.Println("\nInterrupt received, stopping after the current pages (press Ctrl-C again to force quit)...")
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		stop()
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(migrateCmd)
 	migrateCmd.Flags().BoolVar(&resume, "resume", false, "resume migration from where it left off")
+	migrateCmd.Flags().BoolVar(&update, "update", false, "update the memos of already migrated pages instead of creating new ones")
+	migrateCmd.Flags().StringVar(&existingMode, "existing", "", "look up memos already on the server for pages without recorded memos and skip, link or update them")
+	migrateCmd.Flags().StringVar(&existingFilter, "existing-filter", "", "server-side filter for listing existing memos (Memos filter syntax)")
+	migrateCmd.Flags().IntVar(&workers, "workers", 1, "number of pages migrated concurrently (Notion requests stay within its rate limit)")
+	migrateCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "record failed pages in the state and continue with the next ones (exit code 2 if any failed)")
+	migrateCmd.Flags().BoolVar(&retryFailed, "retry-failed", false, "migrate only the pages that failed in earlier runs")
 	migrateCmd.Flags().StringSliceVar(&filterTitles, "filter-title", []string{}, "filter pages by exact title (can be specified multiple times)")
 }
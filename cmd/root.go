@@ -1,14 +1,17 @@
 package cmd
 
 import (
+"errors"
 "os"
 
+"github.com/OneManRepo/notion2memos/internal/migrate"
 "github.com/spf13/cobra"
 )
 
 var (
 cfgFile string
 dryRun  bool
+noCache bool
 )
 
 // rootCmd represents the base command
@@ -19,9 +22,16 @@ var rootCmd = &cobra.Command{
 It supports filtering by title, resume capability, and dry-run mode.`,
 }
 
+// exitPartialSuccess is the exit code of a migration in which some pages
+// failed and the others were migrated
+const exitPartialSuccess = 2
+
 // Execute adds all child commands to the root command and sets flags appropriately
 func Execute() {
 	err := rootCmd.Execute()
+	if errors.Is(err, migrate.ErrPartialSuccess) {
+		os.Exit(exitPartialSuccess)
+	}
 	if err != nil {
 		os.Exit(1)
 	}
@@ -30,4 +40,5 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.notion2memos/config.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "run without actually creating memos (saves to ./dry-run-output/)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "fetch everything from Notion instead of reusing cached responses")
 }
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+"fmt"
+
+"github.com/OneManRepo/notion2memos/internal/config"
+"github.com/OneManRepo/notion2memos/internal/migrate"
+"github.com/spf13/cobra"
+)
+
+var rebuildFilter string
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage the migration state",
+	Long: `The migration state in ~/.notion2memos/state.json records which pages were
+migrated and the names of their memos.`,
+}
+
+var stateRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuild the migration state from the memos on the server",
+	Long: `Scans the memos on the Memos server (of every account in the user mapping)
+and reconstructs the page-to-memo mapping from the hidden source marker each
+migrated memo ends with. The current state file is kept as state.json.bak.
+
+With --dry-run the rebuilt state is summarized but not saved.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return err
+		}
+
+		result, err := migrate.RebuildState(cmd.Context(), cfg, rebuildFilter)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Found %d memos with a source marker: %d pages and %d database snapshots\n",
+			result.Memos, result.Pages, result.Databases)
+		if result.Ignored > 0 {
+			fmt.Printf("Ignored %d memos of outdated page versions or duplicate parts\n", result.Ignored)
+		}
+		if len(result.Incomplete) > 0 {
+			fmt.Printf("%d pages have missing parts and will be completed by the next migration:\n", len(result.Incomplete))
+			for _, source := range result.Incomplete {
+				fmt.Printf("  %s\n", source)
+			}
+		}
+
+		if dryRun {
+			fmt.Println("Dry run: the state file was not changed")
+			return nil
+		}
+
+		backup, err := config.BackupStateFile()
+		if err != nil {
+			return err
+		}
+		if err := result.State.SaveState(); err != nil {
+			return err
+		}
+		if backup != "" {
+			fmt.Printf("Previous state saved to %s\n", backup)
+		}
+		fmt.Println("Migration state has been rebuilt")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateRebuildCmd)
+	stateRebuildCmd.Flags().StringVar(&rebuildFilter, "filter", "", "server-side filter for listing memos (Memos filter syntax)")
+}
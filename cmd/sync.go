@@ -0,0 +1,60 @@
+package cmd
+
+import (
+"errors"
+"fmt"
+
+"github.com/OneManRepo/notion2memos/internal/config"
+"github.com/OneManRepo/notion2memos/internal/migrate"
+"github.com/spf13/cobra"
+)
+
+var (
+syncFull            bool
+syncWorkers         int
+syncContinueOnError bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync pages edited in Notion since the last run",
+	Long: `Finds the pages edited in Notion since the last sync, creates memos for new
+pages and updates the memos of edited ones. A full scan, which the first sync
+always is, also flags pages that were deleted in Notion.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if syncWorkers < 1 {
+			return fmt.Errorf("invalid --workers %d: must be at least 1", syncWorkers)
+		}
+
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return err
+		}
+
+		migrator, err := migrate.NewMigrator(cfg, dryRun, !noCache)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := interruptContext()
+		defer stop()
+
+		err = migrator.Sync(ctx, migrate.SyncOptions{
+			Full:            syncFull,
+			Workers:         syncWorkers,
+			ContinueOnError: syncContinueOnError,
+		})
+		if errors.Is(err, migrate.ErrPartialSuccess) {
+			// The failed pages were listed already
+			cmd.SilenceUsage = true
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().BoolVar(&syncFull, "full", false, "scan all pages instead of those edited since the last sync, and flag deleted pages")
+	syncCmd.Flags().IntVar(&syncWorkers, "workers", 1, "number of pages synced concurrently (Notion requests stay within its rate limit)")
+	syncCmd.Flags().BoolVar(&syncContinueOnError, "continue-on-error", false, "record failed pages in the state and continue with the next ones (exit code 2 if any failed)")
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+"cmp"
+"fmt"
+"maps"
+"os"
+"slices"
+"strings"
+"text/tabwriter"
+
+"github.com/OneManRepo/notion2memos/internal/config"
+"github.com/OneManRepo/notion2memos/internal/migrate"
+"github.com/spf13/cobra"
+)
+
+var tagsPreviewFilterTitles []string
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "Inspect the tags of migrated memos",
+	Long: `Memos are tagged with the titles of their Notion parents, turned into tags
+by the rules in the tags.rules section of the config.`,
+}
+
+var tagsPreviewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Show the tags each page would get",
+	Long: `Lists every page and database snapshot with its Notion parents and the tags
+the tag rules derive from them, followed by how often each tag is used.
+Nothing is written to Memos.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return err
+		}
+
+		migrator, err := migrate.NewMigrator(cfg, dryRun, !noCache)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PAGE\tTAGS\tPARENTS")
+		counts := make(map[string]int)
+		for preview, err := range migrator.PreviewTags(cmd.Context(), tagsPreviewFilterTitles) {
+			if err != nil {
+				w.Flush()
+				return err
+			}
+
+			title := preview.Title
+			if preview.Snapshot {
+				title += " (snapshot)"
+			}
+			tags := make([]string, len(preview.Tags))
+			for i, tag := range preview.Tags {
+				tags[i] = "#" + tag
+				counts[tag]++
+			}
+			parents := strings.Join(preview.Parents, " / ")
+			if preview.Err != nil {
+				parents += fmt.Sprintf(" (incomplete: %v)", preview.Err)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", title, strings.Join(tags, " "), parents)
+		}
+		w.Flush()
+
+		if len(counts) == 0 {
+			return nil
+		}
+		fmt.Println()
+		tags := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+			return cmp.Or(counts[b]-counts[a], strings.Compare(a, b))
+		})
+		for _, tag := range tags {
+			fmt.Printf("  #%-30s %d\n", tag, counts[tag])
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagsCmd)
+	tagsCmd.AddCommand(tagsPreviewCmd)
+	tagsPreviewCmd.Flags().StringSliceVar(&tagsPreviewFilterTitles, "filter-title", nil, "preview only pages with this exact title (can be specified multiple times)")
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Kind is a category of cached Notion objects, stored in a directory of its own
+type Kind string
+
+const (
+	// KindPages holds page metadata (title, parent, properties)
+	KindPages Kind = "pages"
+	// KindChildren holds the block tree of a page
+	KindChildren Kind = "children"
+	// KindBlocks holds single blocks that are parents of pages
+	KindBlocks Kind = "blocks"
+	// KindDatabases holds database metadata
+	KindDatabases Kind = "databases"
+	// KindDataSources holds data source metadata
+	KindDataSources Kind = "data_sources"
+)
+
+// Kinds lists all kinds in display order
+var Kinds = []Kind{KindPages, KindChildren, KindBlocks, KindDatabases, KindDataSources}
+
+// Cache stores Notion responses on disk so that reruns only fetch what changed.
+// A nil *Cache is a disabled cache: lookups miss and writes are dropped.
+type Cache struct {
+	dir    string
+	opened time.Time
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// entry is the file format of a cached object
+type entry struct {
+	// LastEditedTime is the version of the object the data belongs to
+	LastEditedTime string          `json:"last_edited_time,omitempty"`
+	CachedAt       time.Time       `json:"cached_at"`
+	Data           json.RawMessage `json:"data"`
+}
+
+// Stats describes the cached objects of one kind
+type Stats struct {
+	Kind    Kind
+	Entries int
+	Bytes   int64
+}
+
+// Open opens the cache in dir. Entries looked up without a version are only
+// used if they were cached after the cache was opened, i.e. during this run.
+func Open(dir string) *Cache {
+	return &Cache{dir: dir, opened: time.Now()}
+}
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get loads the cached object into v. With a version (the object's
+// last_edited_time), only an entry of exactly that version is used. Without
+// one, edits can't be told apart, so only an entry cached during this run is
+// used; an object renamed in Notion is fetched again by the next run.
+func (c *Cache) Get(kind Kind, id, version string, v any) bool {
+	if c == nil {
+		return false
+	}
+
+	if !c.get(kind, id, version, v) {
+		c.misses.Add(1)
+		return false
+	}
+	c.hits.Add(1)
+	return true
+}
+
+func (c *Cache) get(kind Kind, id, version string, v any) bool {
+	data, err := os.ReadFile(c.path(kind, id))
+	if err != nil {
+		return false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return false
+	}
+	if version != "" && e.LastEditedTime != version {
+		return false
+	}
+	if version == "" && e.CachedAt.Before(c.opened) {
+		return false
+	}
+
+	return json.Unmarshal(e.Data, v) == nil
+}
+
+// Put stores an object at the given version (empty if the object has none)
+func (c *Cache) Put(kind Kind, id, version string, v any) error {
+	if c == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	data, err = json.Marshal(entry{
+		LastEditedTime: version,
+		CachedAt:       time.Now(),
+		Data:           data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	path := c.path(kind, id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file first so that an interrupted run never leaves
+	// a truncated entry behind
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// Hits returns the number of lookups served from the cache
+func (c *Cache) Hits() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.hits.Load()
+}
+
+// Misses returns the number of lookups that had to go to Notion
+func (c *Cache) Misses() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.misses.Load()
+}
+
+// Stats counts the entries and their size per kind
+func (c *Cache) Stats() ([]Stats, error) {
+	var stats []Stats
+	for _, kind := range Kinds {
+		s := Stats{Kind: kind}
+		files, err := os.ReadDir(filepath.Join(c.dir, string(kind)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read cache directory: %w", err)
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			s.Entries++
+			s.Bytes += info.Size()
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// Clear removes all cached entries
+func (c *Cache) Clear() error {
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("failed to remove cache directory: %w", err)
+	}
+	return nil
+}
+
+// path returns the file of an entry. IDs are normalized so that dashed and
+// undashed forms of the same ID share an entry.
+func (c *Cache) path(kind Kind, id string) string {
+	id = strings.ToLower(strings.ReplaceAll(id, "-", ""))
+	return filepath.Join(c.dir, string(kind), filepath.Base(id)+".json")
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,6 +16,155 @@ type Config struct {
 	NotionToken string `mapstructure:"notion_token"`
 	MemosURL    string `mapstructure:"memos_url"`
 	MemosToken  string `mapstructure:"memos_token"`
+
+	// NotionAPIURL overrides the Notion API endpoint (optional), e.g. to
+	// point the migrator at a local fake server
+	NotionAPIURL string `mapstructure:"notion_api_url"`
+
+	// NotionRetry controls retries of failed Notion API requests
+	NotionRetry RetryConfig `mapstructure:"notion_retry"`
+
+	// UserMappingFile maps Notion authors to Memos accounts (optional)
+	UserMappingFile string `mapstructure:"user_mapping_file"`
+
+	// DatabaseSnapshots lists databases rendered as a single table memo
+	// instead of one memo per row
+	DatabaseSnapshots []DatabaseSnapshot `mapstructure:"database_snapshots"`
+
+	// SplitMemos controls how the parts of split memos are connected
+	SplitMemos SplitMemosConfig `mapstructure:"split_memos"`
+
+	// MemoLengthLimit overrides the content length limit read from the
+	// Memos server, in bytes (0 uses the server's limit)
+	MemoLengthLimit int `mapstructure:"memo_length_limit"`
+
+	// Tags controls where tags are placed and whether they are registered
+	Tags TagsConfig `mapstructure:"tags"`
+
+	// DeterministicMemoIDs derives memo IDs from the Notion page ID and part,
+	// so that migrating a page again conflicts with its memos instead of
+	// duplicating them (Memos 0.25 and later)
+	DeterministicMemoIDs bool `mapstructure:"deterministic_memo_ids"`
+}
+
+// RetryConfig controls retries with exponential backoff
+type RetryConfig struct {
+	MaxRetries     int           `mapstructure:"max_retries"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+}
+
+// MinMemoLengthLimit is the shortest memo length limit memos are split for;
+// parts need room for the title, tags and source marker besides the body
+const MinMemoLengthLimit = 2048
+
+// Values of SplitMemosConfig.Links
+const (
+	SplitLinksChain = "chain" // each part references the previous and next part
+	SplitLinksFirst = "first" // every part references the first part
+	SplitLinksNone  = "none"  // parts are connected by "..." markers only
+)
+
+// SplitMemosConfig controls how the parts of split memos are connected
+type SplitMemosConfig struct {
+	// Links is one of SplitLinksChain, SplitLinksFirst or SplitLinksNone
+	Links string `mapstructure:"links"`
+	// PartsFooter appends a "Parts:" line linking all parts to every part
+	PartsFooter bool `mapstructure:"parts_footer"`
+}
+
+// Values of TagsConfig.Placement
+const (
+	TagsAfterTitle = "title" // tag line under the title
+	TagsAtEnd      = "end"   // tag line at the end of the memo
+	TagsBoth       = "both"  // tag line under the title and at the end
+)
+
+// TagsConfig controls the tags of memos
+type TagsConfig struct {
+	// Placement is one of TagsAfterTitle, TagsAtEnd or TagsBoth
+	Placement string `mapstructure:"placement"`
+	// Native registers the tags in the server's tag list on Memos versions
+	// that keep one apart from the content (0.18)
+	Native bool `mapstructure:"native"`
+	// Rules turn the titles of a page's parents into its tags, applied in
+	// order (DefaultTagRules if not configured)
+	Rules []TagRule `mapstructure:"rules"`
+}
+
+// Values of TagRule.Only
+const (
+	TagParentPage     = "page"     // tags of parent pages
+	TagParentDatabase = "database" // tags of parent databases and data sources
+)
+
+// TagRule is one step of TagsConfig.Rules. Tags start out as the titles of
+// a page's parents, outermost first. Exactly one action is set per rule.
+type TagRule struct {
+	// Rename replaces tags equal to From with To
+	Rename *TagRename `mapstructure:"rename"`
+	// Lowercase lowercases the tags
+	Lowercase bool `mapstructure:"lowercase"`
+	// Replace replaces the matches of a regular expression in the tags
+	Replace *TagReplace `mapstructure:"replace"`
+	// Drop removes the tags matching a regular expression
+	Drop string `mapstructure:"drop"`
+	// Map replaces the tag of a parent page or database with a fixed tag
+	Map *TagMap `mapstructure:"map"`
+	// MaxDepth keeps the tags of the outermost MaxDepth remaining parents
+	MaxDepth int `mapstructure:"max_depth"`
+	// Add adds fixed tags to the pages in or below a database
+	Add *TagAdd `mapstructure:"add"`
+
+	// Only restricts Rename, Lowercase, Replace and Drop to the tags of
+	// parent pages (TagParentPage) or databases (TagParentDatabase)
+	Only string `mapstructure:"only"`
+}
+
+// TagRename is the action of a rename rule
+type TagRename struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// TagReplace is the action of a replace rule; With may refer to groups of
+// Pattern as $1
+type TagReplace struct {
+	Pattern string `mapstructure:"pattern"`
+	With    string `mapstructure:"with"`
+}
+
+// TagMap is the action of a map rule
+type TagMap struct {
+	// Parent is the ID or exact title of a parent page or database
+	Parent string `mapstructure:"parent"`
+	Tag    string `mapstructure:"tag"`
+}
+
+// TagAdd is the action of an add rule
+type TagAdd struct {
+	// Database is the ID or exact title of the database
+	Database string   `mapstructure:"database"`
+	Tags     []string `mapstructure:"tags"`
+}
+
+// DefaultTagRules are the tag rules unless the config sets its own: parent
+// pages titled like dates ("08.12. Trip", "01.25 Notes") don't become tags,
+// and the journal database "Tagebuch" is tagged #tagebuch
+var DefaultTagRules = []TagRule{
+	{Drop: `^[0-9]{2}\.[0-9]{2}\.? `, Only: TagParentPage},
+	{Rename: &TagRename{From: "Tagebuch", To: "tagebuch"}},
+}
+
+// DatabaseSnapshot configures snapshot mode for one database
+type DatabaseSnapshot struct {
+	// Database is the database ID or its exact title
+	Database string `mapstructure:"database"`
+	// Properties are the table columns in order (all properties if empty)
+	Properties []string `mapstructure:"properties"`
+	// Inline renders the table inside the page that contains the database
+	// instead of as a memo of its own
+	Inline bool `mapstructure:"inline"`
 }
 
 // Load loads configuration from file and environment variables
@@ -44,6 +196,15 @@ func Load(configPath string) (*Config, error) {
 	v.BindEnv("notion_token", "NOTION_TOKEN")
 	v.BindEnv("memos_url", "MEMOS_URL")
 	v.BindEnv("memos_token", "MEMOS_TOKEN")
+	v.BindEnv("notion_api_url", "NOTION_API_URL")
+
+	// Defaults
+	v.SetDefault("notion_retry.max_retries", 5)
+	v.SetDefault("notion_retry.initial_backoff", "1s")
+	v.SetDefault("notion_retry.max_backoff", "60s")
+	v.SetDefault("split_memos.links", SplitLinksChain)
+	v.SetDefault("tags.placement", TagsAfterTitle)
+	v.SetDefault("tags.native", true)
 
 	// Read config file
 	if err := v.ReadInConfig(); err != nil {
@@ -57,6 +218,10 @@ func Load(configPath string) (*Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	// An empty list configures no rules; only a missing one gets the defaults
+	if !v.IsSet("tags.rules") {
+		cfg.Tags.Rules = slices.Clone(DefaultTagRules)
+	}
 
 	// Validate required fields
 	if err := cfg.Validate(); err != nil {
@@ -77,9 +242,81 @@ func (c *Config) Validate() error {
 	if c.MemosToken == "" {
 		return fmt.Errorf("memos_token is required (set via config file or MEMOS_TOKEN env var)")
 	}
+	if c.NotionRetry.MaxRetries < 0 {
+		return fmt.Errorf("notion_retry.max_retries must not be negative")
+	}
+	switch c.SplitMemos.Links {
+	case SplitLinksChain, SplitLinksFirst, SplitLinksNone:
+	default:
+		return fmt.Errorf("split_memos.links must be %q, %q or %q", SplitLinksChain, SplitLinksFirst, SplitLinksNone)
+	}
+	if c.MemoLengthLimit != 0 && c.MemoLengthLimit < MinMemoLengthLimit {
+		return fmt.Errorf("memo_length_limit must be at least %d (or 0 to use the server's limit)", MinMemoLengthLimit)
+	}
+	switch c.Tags.Placement {
+	case TagsAfterTitle, TagsAtEnd, TagsBoth:
+	default:
+		return fmt.Errorf("tags.placement must be %q, %q or %q", TagsAfterTitle, TagsAtEnd, TagsBoth)
+	}
+	for i, rule := range c.Tags.Rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("tags.rules[%d]: %w", i, err)
+		}
+	}
+	for i, snapshot := range c.DatabaseSnapshots {
+		if snapshot.Database == "" {
+			return fmt.Errorf("database_snapshots[%d]: database ID or title is required", i)
+		}
+	}
 	return nil
 }
 
+// validate checks that the rule has exactly one complete action
+func (r *TagRule) validate() error {
+	actions := 0
+	for _, set := range []bool{r.Rename != nil, r.Lowercase, r.Replace != nil, r.Drop != "", r.Map != nil, r.MaxDepth != 0, r.Add != nil} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		return fmt.Errorf("a rule needs exactly one of rename, lowercase, replace, drop, map, max_depth or add")
+	}
+
+	switch {
+	case r.Rename != nil && r.Rename.From == "":
+		return fmt.Errorf("rename.from is required")
+	case r.Replace != nil && r.Replace.Pattern == "":
+		return fmt.Errorf("replace.pattern is required")
+	case r.Map != nil && (r.Map.Parent == "" || r.Map.Tag == ""):
+		return fmt.Errorf("map.parent and map.tag are required")
+	case r.MaxDepth < 0:
+		return fmt.Errorf("max_depth must not be negative")
+	case r.Add != nil && (r.Add.Database == "" || len(r.Add.Tags) == 0):
+		return fmt.Errorf("add.database and add.tags are required")
+	}
+	for _, pattern := range []string{r.Drop, r.replacePattern()} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+	}
+
+	switch r.Only {
+	case "", TagParentPage, TagParentDatabase:
+	default:
+		return fmt.Errorf("only must be %q or %q", TagParentPage, TagParentDatabase)
+	}
+	return nil
+}
+
+// replacePattern returns the pattern of a replace rule, empty for other rules
+func (r *TagRule) replacePattern() string {
+	if r.Replace == nil {
+		return ""
+	}
+	return r.Replace.Pattern
+}
+
 // GetConfigDir returns the default config directory path
 func GetConfigDir() (string, error) {
 	home, err := os.UserHomeDir()
@@ -89,6 +326,15 @@ func GetConfigDir() (string, error) {
 	return filepath.Join(home, ".notion2memos"), nil
 }
 
+// GetCacheDir returns the directory of the Notion response cache
+func GetCacheDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "cache"), nil
+}
+
 // GetConfigPath returns the default config file path
 func GetConfigPath() (string, error) {
 	configDir, err := GetConfigDir()
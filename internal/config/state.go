@@ -6,18 +6,69 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 // State tracks the migration state
 type State struct {
-	ProcessedPages map[string]bool `json:"processed_pages"`
-	mu             sync.RWMutex
+	ProcessedPages map[string]bool         `json:"processed_pages"`
+	SkippedPages   map[string]*SkippedPage `json:"skipped_pages,omitempty"`
+	// FailedPages holds pages that failed in a run with --continue-on-error
+	FailedPages map[string]*FailedPage `json:"failed_pages,omitempty"`
+	// Memos holds the names of the memos created for each page, in part order
+	Memos map[string][]string `json:"memos,omitempty"`
+	// PendingMemos holds memos that were created but still need their
+	// display time, keyed by memo name
+	PendingMemos map[string]*PendingMemo `json:"pending_memos,omitempty"`
+	// Pages holds what was migrated of each page, for syncing later edits
+	Pages map[string]*PageRecord `json:"pages,omitempty"`
+	// SyncedAt is when the last complete sync started
+	SyncedAt time.Time `json:"synced_at,omitzero"`
+	mu       sync.RWMutex
+}
+
+// PageRecord records the version of a page its memos were written from
+type PageRecord struct {
+	Title string `json:"title,omitempty"`
+	// LastEdited is the page's last_edited_time as reported by Notion
+	LastEdited string `json:"last_edited_time"`
+	// ContentHash is the hash of the Markdown the page rendered to, empty if
+	// unknown (e.g. for records rebuilt from the server)
+	ContentHash string `json:"content_hash,omitempty"`
+	// DeletedAt is set once a sync no longer finds the page in Notion
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// PendingMemo records a memo whose display time couldn't be set
+type PendingMemo struct {
+	PageID      string    `json:"page_id"`
+	DisplayTime time.Time `json:"display_time"`
+}
+
+// SkippedPage records why a page was skipped
+type SkippedPage struct {
+	Title  string    `json:"title"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+// FailedPage records why a page failed
+type FailedPage struct {
+	Title string    `json:"title"`
+	Class string    `json:"class"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
 }
 
 // NewState creates a new empty state
 func NewState() *State {
 	return &State{
 		ProcessedPages: make(map[string]bool),
+		SkippedPages:   make(map[string]*SkippedPage),
+		FailedPages:    make(map[string]*FailedPage),
+		Memos:          make(map[string][]string),
+		PendingMemos:   make(map[string]*PendingMemo),
+		Pages:          make(map[string]*PageRecord),
 	}
 }
 
@@ -46,6 +97,21 @@ func LoadState() (*State, error) {
 	if state.ProcessedPages == nil {
 		state.ProcessedPages = make(map[string]bool)
 	}
+	if state.SkippedPages == nil {
+		state.SkippedPages = make(map[string]*SkippedPage)
+	}
+	if state.FailedPages == nil {
+		state.FailedPages = make(map[string]*FailedPage)
+	}
+	if state.Memos == nil {
+		state.Memos = make(map[string][]string)
+	}
+	if state.PendingMemos == nil {
+		state.PendingMemos = make(map[string]*PendingMemo)
+	}
+	if state.Pages == nil {
+		state.Pages = make(map[string]*PageRecord)
+	}
 
 	return &state, nil
 }
@@ -71,7 +137,29 @@ func (s *State) SaveState() error {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	if err := os.WriteFile(statePath, data, 0644); err != nil {
+	// Write to a temporary file first so that a run killed while saving
+	// never leaves a truncated state file behind
+	tmp, err := os.CreateTemp(stateDir, ".state-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	// CreateTemp creates the file readable by its owner only
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), statePath); err != nil {
+		os.Remove(tmp.Name())
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
@@ -83,6 +171,153 @@ func (s *State) MarkProcessed(pageID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.ProcessedPages[pageID] = true
+	delete(s.SkippedPages, pageID)
+	delete(s.FailedPages, pageID)
+}
+
+// MarkSkipped records that a page was skipped and why
+func (s *State) MarkSkipped(pageID, title, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SkippedPages[pageID] = &SkippedPage{
+		Title:  title,
+		Reason: reason,
+		Time:   time.Now(),
+	}
+	delete(s.FailedPages, pageID)
+}
+
+// MarkFailed records that a page failed, with the class and message of the error
+func (s *State) MarkFailed(pageID, title, class, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.FailedPages[pageID] = &FailedPage{
+		Title: title,
+		Class: class,
+		Error: message,
+		Time:  time.Now(),
+	}
+}
+
+// IsFailed checks if a page failed in an earlier run
+func (s *State) IsFailed(pageID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.FailedPages[pageID] != nil
+}
+
+// FailedPageCount returns the number of pages recorded as failed
+func (s *State) FailedPageCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.FailedPages)
+}
+
+// SetMemos records the memos created for a page
+func (s *State) SetMemos(pageID string, names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Memos[pageID] = append([]string(nil), names...)
+}
+
+// GetMemos returns the memos recorded for a page
+func (s *State) GetMemos(pageID string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.Memos[pageID]...)
+}
+
+// AddPendingMemo records a memo of a page that still needs its display time
+func (s *State) AddPendingMemo(name, pageID string, displayTime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PendingMemos[name] = &PendingMemo{PageID: pageID, DisplayTime: displayTime}
+}
+
+// IsPendingMemo checks if a memo still needs its display time
+func (s *State) IsPendingMemo(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.PendingMemos[name] != nil
+}
+
+// RemovePendingMemo removes a memo whose display time was set
+func (s *State) RemovePendingMemo(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.PendingMemos, name)
+}
+
+// PendingMemoCount returns the number of memos that still need their display time
+func (s *State) PendingMemoCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.PendingMemos)
+}
+
+// RecordPage records the version of a page its memos were written from
+func (s *State) RecordPage(pageID, title, lastEdited, contentHash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Pages[pageID] = &PageRecord{
+		Title:       title,
+		LastEdited:  lastEdited,
+		ContentHash: contentHash,
+	}
+}
+
+// GetPageRecord returns a copy of the record of a page, nil if there is none
+func (s *State) GetPageRecord(pageID string) *PageRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.Pages[pageID]
+	if !ok {
+		return nil
+	}
+	copied := *record
+	return &copied
+}
+
+// MarkDeleted flags a recorded page as deleted in Notion, or clears the flag
+// if the page turned up again. It reports whether the flag changed.
+func (s *State) MarkDeleted(pageID string, deleted bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.Pages[pageID]
+	if !ok || deleted == !record.DeletedAt.IsZero() {
+		return false
+	}
+	if deleted {
+		record.DeletedAt = time.Now()
+	} else {
+		record.DeletedAt = time.Time{}
+	}
+	return true
+}
+
+// RecordedPageIDs returns the IDs of all pages with a record
+func (s *State) RecordedPageIDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.Pages))
+	for id := range s.Pages {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// LastSync returns when the last complete sync started, zero if there was none
+func (s *State) LastSync() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.SyncedAt
+}
+
+// SetLastSync records when a complete sync started
+func (s *State) SetLastSync(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SyncedAt = t
 }
 
 // IsProcessed checks if a page has been processed
@@ -97,6 +332,12 @@ func (s *State) ClearState() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.ProcessedPages = make(map[string]bool)
+	s.SkippedPages = make(map[string]*SkippedPage)
+	s.FailedPages = make(map[string]*FailedPage)
+	s.Memos = make(map[string][]string)
+	s.PendingMemos = make(map[string]*PendingMemo)
+	s.Pages = make(map[string]*PageRecord)
+	s.SyncedAt = time.Time{}
 }
 
 // GetStatePath returns the state file path
@@ -108,6 +349,29 @@ func GetStatePath() (string, error) {
 	return filepath.Join(configDir, "state.json"), nil
 }
 
+// BackupStateFile copies the state file next to itself as state.json.bak and
+// returns the backup's path, or "" if there is no state file
+func BackupStateFile() (string, error) {
+	statePath, err := GetStatePath()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	backupPath := statePath + ".bak"
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write state backup: %w", err)
+	}
+	return backupPath, nil
+}
+
 // ClearStateFile removes the state file from disk
 func ClearStateFile() error {
 	statePath, err := GetStatePath()
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// UserMapping maps Notion users to Memos accounts for multi-user migrations
+type UserMapping struct {
+	// Property is the people property that decides the author of a page.
+	// Defaults to the page's created_by user.
+	Property string             `mapstructure:"property"`
+	Default  MemosAccount       `mapstructure:"default"`
+	Users    []UserMappingEntry `mapstructure:"users"`
+}
+
+// UserMappingEntry maps one Notion user (by ID or email) to a Memos account
+type UserMappingEntry struct {
+	Notion       string `mapstructure:"notion"`
+	MemosAccount `mapstructure:",squash"`
+}
+
+// MemosAccount is the access token of the Memos user pages are created as
+type MemosAccount struct {
+	Token string `mapstructure:"token"`
+}
+
+// LoadUserMapping loads a user mapping file
+func LoadUserMapping(path string) (*UserMapping, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read user mapping file: %w", err)
+	}
+
+	var mapping UserMapping
+	if err := v.Unmarshal(&mapping); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user mapping: %w", err)
+	}
+
+	if err := mapping.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid user mapping %s: %w", path, err)
+	}
+
+	return &mapping, nil
+}
+
+// Validate checks that every entry names a Notion user and has a token
+func (m *UserMapping) Validate() error {
+	seen := make(map[string]bool)
+	for i, entry := range m.Users {
+		key := NormalizeNotionUser(entry.Notion)
+		if key == "" {
+			return fmt.Errorf("users[%d]: notion user ID or email is required", i)
+		}
+		if entry.Token == "" {
+			return fmt.Errorf("users[%d] (%s): token is required", i, entry.Notion)
+		}
+		if seen[key] {
+			return fmt.Errorf("users[%d]: %s is mapped more than once", i, entry.Notion)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Lookup returns the account mapped to any of the given Notion user keys
+// (IDs or emails)
+func (m *UserMapping) Lookup(keys ...string) (MemosAccount, bool) {
+	for _, key := range keys {
+		key = NormalizeNotionUser(key)
+		if key == "" {
+			continue
+		}
+		for _, entry := range m.Users {
+			if NormalizeNotionUser(entry.Notion) == key {
+				return entry.MemosAccount, true
+			}
+		}
+	}
+	return MemosAccount{}, false
+}
+
+// NormalizeNotionUser normalizes a Notion user ID or email for comparison.
+// IDs are compared without dashes, emails case-insensitively.
+func NormalizeNotionUser(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !strings.Contains(key, "@") {
+		key = strings.ReplaceAll(key, "-", "")
+	}
+	return key
+}
@@ -0,0 +1,175 @@
+package fakeserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// uploadSizeLimitMB is the upload limit reported by the storage settings
+const uploadSizeLimitMB = 32
+
+// Attachment is an attachment uploaded through the fake Memos API. Only the
+// size of the content is kept.
+type Attachment struct {
+	Name       string `json:"name"`
+	CreateTime string `json:"createTime"`
+	Filename   string `json:"filename"`
+	Type       string `json:"type"`
+	Size       string `json:"size"`
+	Memo       string `json:"memo,omitempty"`
+}
+
+// handleCreateAttachment stores an uploaded attachment
+func (s *Server) handleCreateAttachment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Filename string `json:"filename"`
+		Type     string `json:"type"`
+		Memo     string `json:"memo"`
+		Content  string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.record(r, nil, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+	content, err := base64.StdEncoding.DecodeString(req.Content)
+	if err != nil {
+		s.record(r, nil, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid_argument", "content is not base64: "+err.Error())
+		return
+	}
+	if len(content) > uploadSizeLimitMB<<20 {
+		s.record(r, nil, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid_argument", "file size exceeds the limit")
+		return
+	}
+
+	s.mu.Lock()
+	attachment := &Attachment{
+		Name:       fmt.Sprintf("attachments/%d", s.nextAttachmentID),
+		CreateTime: time.Now().UTC().Format(time.RFC3339),
+		Filename:   req.Filename,
+		Type:       req.Type,
+		Size:       strconv.Itoa(len(content)),
+		Memo:       req.Memo,
+	}
+	s.nextAttachmentID++
+	s.attachments = append(s.attachments, attachment)
+	created := *attachment
+	s.mu.Unlock()
+
+	// The body holds the whole file, so only its metadata is recorded
+	meta, _ := json.Marshal(created)
+	s.record(r, meta, http.StatusOK)
+	writeJSON(w, http.StatusOK, created)
+}
+
+// handleListAttachments lists all attachments
+func (s *Server) handleListAttachments(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"attachments": s.findAttachments(""),
+	})
+}
+
+// handleListMemoAttachments lists the attachments of a memo
+func (s *Server) handleListMemoAttachments(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"attachments": s.findAttachments("memos/" + r.PathValue("id")),
+	})
+}
+
+// handleSetMemoAttachments links exactly the given attachments to a memo
+func (s *Server) handleSetMemoAttachments(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var req struct {
+		Attachments []struct {
+			Name string `json:"name"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.record(r, body, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+
+	memoName := "memos/" + r.PathValue("id")
+	linked := make(map[string]bool)
+	for _, ref := range req.Attachments {
+		linked[ref.Name] = true
+	}
+
+	s.mu.Lock()
+	if s.findMemo(memoName) == nil {
+		s.mu.Unlock()
+		s.record(r, body, http.StatusNotFound)
+		writeError(w, http.StatusNotFound, "not_found", "memo not found")
+		return
+	}
+	for _, attachment := range s.attachments {
+		if linked[attachment.Name] {
+			attachment.Memo = memoName
+		} else if attachment.Memo == memoName {
+			attachment.Memo = ""
+		}
+	}
+	s.mu.Unlock()
+
+	s.record(r, body, http.StatusOK)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// handleDeleteAttachment deletes an attachment
+func (s *Server) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	name := "attachments/" + r.PathValue("id")
+
+	s.mu.Lock()
+	found := false
+	for i, attachment := range s.attachments {
+		if attachment.Name == name {
+			s.attachments = append(s.attachments[:i], s.attachments[i+1:]...)
+			found = true
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if !found {
+		s.record(r, nil, http.StatusNotFound)
+		writeError(w, http.StatusNotFound, "not_found", "attachment not found")
+		return
+	}
+	s.record(r, nil, http.StatusOK)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// handleStorageSetting reports the workspace storage setting
+func (s *Server) handleStorageSetting(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name": "workspace/settings/STORAGE",
+		"storageSetting": map[string]interface{}{
+			"storageType":       "DATABASE",
+			"uploadSizeLimitMb": strconv.Itoa(uploadSizeLimitMB),
+		},
+	})
+}
+
+// findAttachments returns copies of the attachments of a memo, or of all
+// attachments if memoName is empty
+func (s *Server) findAttachments(memoName string) []Attachment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attachments := []Attachment{}
+	for _, attachment := range s.attachments {
+		if memoName == "" || attachment.Memo == memoName {
+			attachments = append(attachments, *attachment)
+		}
+	}
+	return attachments
+}
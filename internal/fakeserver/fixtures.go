@@ -0,0 +1,232 @@
+package fakeserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fixtures holds the Notion objects served by the fake server. They are read
+// from a directory with this layout, where every file holds one object or an
+// array of objects exactly as the Notion API returns them:
+//
+//	pages/*.json          page objects (returned by search and retrieve)
+//	blocks/<id>.json      child blocks of the page or block <id> (their
+//	                      parent is filled in if the fixture omits it)
+//	databases/*.json      database objects
+//	data_sources/*.json   data source objects (rows are the pages they parent)
+//	users/*.json          user objects
+type Fixtures struct {
+	Pages       []Object
+	Databases   []Object
+	DataSources []Object
+	Users       []Object
+	Blocks      map[string][]json.RawMessage // keyed by normalized parent ID
+	BlockByID   map[string]json.RawMessage   // keyed by normalized block ID
+}
+
+// Object is a fixture with the fields needed to index it
+type Object struct {
+	ID     string                 `json:"id"`
+	Parent map[string]interface{} `json:"parent"`
+	Raw    json.RawMessage        `json:"-"`
+
+	// LastEditedTime orders search results
+	LastEditedTime string `json:"last_edited_time"`
+	// Title is the plain text title used to match search queries
+	Title string `json:"-"`
+}
+
+// LoadFixtures reads the fixtures directory
+func LoadFixtures(dir string) (*Fixtures, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("fixtures path %s is not a directory", dir)
+	}
+
+	f := &Fixtures{
+		Blocks:    make(map[string][]json.RawMessage),
+		BlockByID: make(map[string]json.RawMessage),
+	}
+
+	for _, kind := range []struct {
+		subdir string
+		target *[]Object
+	}{
+		{"pages", &f.Pages},
+		{"databases", &f.Databases},
+		{"data_sources", &f.DataSources},
+		{"users", &f.Users},
+	} {
+		objects, err := loadObjects(filepath.Join(dir, kind.subdir))
+		if err != nil {
+			return nil, err
+		}
+		*kind.target = objects
+	}
+
+	blockFiles, err := filepath.Glob(filepath.Join(dir, "blocks", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range blockFiles {
+		raws, err := readRawObjects(path)
+		if err != nil {
+			return nil, err
+		}
+		parentID := strings.TrimSuffix(filepath.Base(path), ".json")
+		parentType := "block_id"
+		if find(f.Pages, parentID) != nil {
+			parentType = "page_id"
+		}
+
+		for i, raw := range raws {
+			raw, id, err := withParent(raw, parentType, parentID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+			}
+			raws[i] = raw
+			f.BlockByID[normalizeID(id)] = raw
+		}
+		f.Blocks[normalizeID(parentID)] = raws
+	}
+
+	return f, nil
+}
+
+// loadObjects reads all objects of one fixture subdirectory, sorted by file name
+func loadObjects(dir string) ([]Object, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var objects []Object
+	for _, path := range paths {
+		raws, err := readRawObjects(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range raws {
+			var obj Object
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+			}
+			if obj.ID == "" {
+				return nil, fmt.Errorf("fixture in %s has no id", path)
+			}
+			obj.Raw = raw
+			obj.Title = extractTitle(raw)
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
+// withParent sets the parent of a block fixture unless it already has one
+// and returns the block with its ID
+func withParent(raw json.RawMessage, parentType, parentID string) (json.RawMessage, string, error) {
+	var block map[string]interface{}
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return nil, "", err
+	}
+	id, _ := block["id"].(string)
+
+	if _, ok := block["parent"]; ok {
+		return raw, id, nil
+	}
+	block["parent"] = map[string]interface{}{
+		"type":     parentType,
+		parentType: parentID,
+	}
+
+	raw, err := json.Marshal(block)
+	return raw, id, err
+}
+
+// readRawObjects reads a file holding one JSON object or an array of objects
+func readRawObjects(path string) ([]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var raws []json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		return raws, nil
+	}
+
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("fixture %s is not valid JSON", path)
+	}
+	return []json.RawMessage{data}, nil
+}
+
+// extractTitle returns the plain text title of a page, database or data source
+func extractTitle(raw json.RawMessage) string {
+	var obj struct {
+		Title []struct {
+			PlainText string `json:"plain_text"`
+		} `json:"title"`
+		Name       string `json:"name"`
+		Properties map[string]struct {
+			Type  string `json:"type"`
+			Title []struct {
+				PlainText string `json:"plain_text"`
+			} `json:"title"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return ""
+	}
+
+	var title strings.Builder
+	for _, rt := range obj.Title {
+		title.WriteString(rt.PlainText)
+	}
+	for _, prop := range obj.Properties {
+		if prop.Type == "title" {
+			for _, rt := range prop.Title {
+				title.WriteString(rt.PlainText)
+			}
+		}
+	}
+	if title.Len() == 0 {
+		return obj.Name
+	}
+	return title.String()
+}
+
+// find returns the object with the given ID
+func find(objects []Object, id string) *Object {
+	id = normalizeID(id)
+	for i := range objects {
+		if normalizeID(objects[i].ID) == id {
+			return &objects[i]
+		}
+	}
+	return nil
+}
+
+// parentID returns the ID stored under key in an object's parent
+func (o *Object) parentID(key string) string {
+	id, _ := o.Parent[key].(string)
+	return normalizeID(id)
+}
+
+// normalizeID strips the dashes from a Notion ID
+func normalizeID(id string) string {
+	return strings.ReplaceAll(strings.ToLower(id), "-", "")
+}
@@ -0,0 +1,184 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// listResponse is Notion's paginated list shape
+type listResponse struct {
+	Object     string            `json:"object"`
+	Results    []json.RawMessage `json:"results"`
+	NextCursor *string           `json:"next_cursor"`
+	HasMore    bool              `json:"has_more"`
+}
+
+// handleSearch serves pages or data sources whose title contains the query
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	f := s.notionFixtures()
+	var req struct {
+		Query       string `json:"query"`
+		StartCursor string `json:"start_cursor"`
+		PageSize    int    `json:"page_size"`
+		Filter      *struct {
+			Value string `json:"value"`
+		} `json:"filter"`
+		Sort *struct {
+			Direction string `json:"direction"`
+		} `json:"sort"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	var candidates []Object
+	if req.Filter == nil || req.Filter.Value == "page" {
+		candidates = append(candidates, f.Pages...)
+	}
+	if req.Filter == nil || req.Filter.Value == "data_source" {
+		candidates = append(candidates, f.DataSources...)
+	}
+
+	query := strings.ToLower(req.Query)
+	var matches []Object
+	for _, obj := range candidates {
+		if query == "" || strings.Contains(strings.ToLower(obj.Title), query) {
+			matches = append(matches, obj)
+		}
+	}
+
+	if req.Sort != nil {
+		sort.SliceStable(matches, func(i, j int) bool {
+			if req.Sort.Direction == "ascending" {
+				return matches[i].LastEditedTime < matches[j].LastEditedTime
+			}
+			return matches[i].LastEditedTime > matches[j].LastEditedTime
+		})
+	}
+
+	writeJSON(w, http.StatusOK, paginate(raws(matches), req.StartCursor, req.PageSize))
+}
+
+// handleRetrievePage serves a page by ID
+func (s *Server) handleRetrievePage(w http.ResponseWriter, r *http.Request) {
+	f := s.notionFixtures()
+	serveObject(w, f.Pages, r.PathValue("id"), "page")
+}
+
+// handleRetrieveDatabase serves a database by ID
+func (s *Server) handleRetrieveDatabase(w http.ResponseWriter, r *http.Request) {
+	f := s.notionFixtures()
+	serveObject(w, f.Databases, r.PathValue("id"), "database")
+}
+
+// handleRetrieveDataSource serves a data source by ID
+func (s *Server) handleRetrieveDataSource(w http.ResponseWriter, r *http.Request) {
+	f := s.notionFixtures()
+	serveObject(w, f.DataSources, r.PathValue("id"), "data source")
+}
+
+// handleRetrieveUser serves a user by ID
+func (s *Server) handleRetrieveUser(w http.ResponseWriter, r *http.Request) {
+	f := s.notionFixtures()
+	serveObject(w, f.Users, r.PathValue("id"), "user")
+}
+
+// handleRetrieveBlock serves a single block by ID
+func (s *Server) handleRetrieveBlock(w http.ResponseWriter, r *http.Request) {
+	f := s.notionFixtures()
+	raw, ok := f.BlockByID[normalizeID(r.PathValue("id"))]
+	if !ok {
+		writeError(w, http.StatusNotFound, "object_not_found", "Could not find block with ID: "+r.PathValue("id")+".")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(raw)
+}
+
+// handleBlockChildren serves the child blocks of a page or block
+func (s *Server) handleBlockChildren(w http.ResponseWriter, r *http.Request) {
+	f := s.notionFixtures()
+	id := normalizeID(r.PathValue("id"))
+	blocks, ok := f.Blocks[id]
+	if !ok && find(f.Pages, id) == nil {
+		writeError(w, http.StatusNotFound, "object_not_found", "Could not find block with ID: "+r.PathValue("id")+".")
+		return
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	writeJSON(w, http.StatusOK, paginate(blocks, r.URL.Query().Get("start_cursor"), pageSize))
+}
+
+// handleQueryDataSource serves the pages whose parent is the data source
+func (s *Server) handleQueryDataSource(w http.ResponseWriter, r *http.Request) {
+	f := s.notionFixtures()
+	dataSource := find(f.DataSources, r.PathValue("id"))
+	if dataSource == nil {
+		writeError(w, http.StatusNotFound, "object_not_found", "Could not find data source with ID: "+r.PathValue("id")+".")
+		return
+	}
+
+	var req struct {
+		StartCursor string `json:"start_cursor"`
+		PageSize    int    `json:"page_size"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	id := normalizeID(dataSource.ID)
+	var rows []Object
+	for _, page := range f.Pages {
+		if page.parentID("data_source_id") == id {
+			rows = append(rows, page)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, paginate(raws(rows), req.StartCursor, req.PageSize))
+}
+
+// serveObject writes the object with the given ID or a 404 error
+func serveObject(w http.ResponseWriter, objects []Object, id, kind string) {
+	obj := find(objects, id)
+	if obj == nil {
+		writeError(w, http.StatusNotFound, "object_not_found", "Could not find "+kind+" with ID: "+id+".")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(obj.Raw)
+}
+
+// paginate returns one page of results; cursors are offsets into the list
+func paginate(results []json.RawMessage, cursor string, pageSize int) listResponse {
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 100
+	}
+	start, _ := strconv.Atoi(cursor)
+	start = min(max(start, 0), len(results))
+	end := min(start+pageSize, len(results))
+
+	resp := listResponse{
+		Object:  "list",
+		Results: results[start:end],
+		HasMore: end < len(results),
+	}
+	if resp.Results == nil {
+		resp.Results = []json.RawMessage{}
+	}
+	if resp.HasMore {
+		next := strconv.Itoa(end)
+		resp.NextCursor = &next
+	}
+	return resp
+}
+
+// raws returns the raw JSON of the objects
+func raws(objects []Object) []json.RawMessage {
+	result := make([]json.RawMessage, len(objects))
+	for i, obj := range objects {
+		result[i] = obj.Raw
+	}
+	return result
+}
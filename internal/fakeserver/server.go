@@ -0,0 +1,503 @@
+// Package fakeserver implements a local stand-in for the Notion and Memos
+// APIs. Notion objects are served from a fixtures directory and Memos writes
+// are recorded, so migrations can be run end to end without network access.
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMemosVersion is the Memos version the server reports by default.
+// The Memos endpoints follow the API of this version.
+const DefaultMemosVersion = "0.25.0"
+
+// DefaultContentLengthLimit is the memo content limit in bytes the server
+// enforces and reports by default, the Memos default
+const DefaultContentLengthLimit = 8 * 1024
+
+// Server serves Notion fixtures under /v1 and a recording Memos API under /api/v1
+type Server struct {
+	mux *http.ServeMux
+
+	mu         sync.Mutex
+	fixtures   *Fixtures
+	calls      []Call
+	memos      []*Memo
+	nextMemoID int
+	recordPath string
+
+	attachments      []*Attachment
+	nextAttachmentID int
+	memosVersion     string
+	contentLimit     int
+
+	// Failures still to inject, see FailDisplayTime, FailDeletes and FailPages
+	displayTimeFailures int
+	deleteFailures      int
+	failingPages        []string
+}
+
+// Call is a recorded Memos API request
+type Call struct {
+	Time   time.Time       `json:"time"`
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Token  string          `json:"token,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Status int             `json:"status"`
+}
+
+// Memo is a memo created through the fake Memos API
+type Memo struct {
+	Name        string `json:"name"`
+	UID         string `json:"uid"`
+	Creator     string `json:"creator"`
+	CreateTime  string `json:"createTime"`
+	UpdateTime  string `json:"updateTime"`
+	DisplayTime string `json:"displayTime"`
+	Content     string `json:"content"`
+	// Relations are the names of the memos this memo references
+	Relations []string `json:"relations,omitempty"`
+}
+
+// New creates a server for the fixtures in dir
+func New(dir string) (*Server, error) {
+	fixtures, err := LoadFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		fixtures:   fixtures,
+		mux:        http.NewServeMux(),
+		nextMemoID: 1,
+
+		nextAttachmentID: 1,
+		memosVersion:     DefaultMemosVersion,
+		contentLimit:     DefaultContentLengthLimit,
+	}
+	s.routes()
+	return s, nil
+}
+
+// Reload replaces the Notion fixtures with the ones in dir, e.g. to edit
+// pages between runs. Memos and recorded calls are kept.
+func (s *Server) Reload(dir string) error {
+	fixtures, err := LoadFixtures(dir)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures = fixtures
+	return nil
+}
+
+// notionFixtures returns the fixtures currently served
+func (s *Server) notionFixtures() *Fixtures {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fixtures
+}
+
+// SetMemosVersion sets the version reported by the workspace profile, e.g.
+// to check how the client handles unsupported servers
+func (s *Server) SetMemosVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.memosVersion = version
+}
+
+// SetContentLengthLimit sets the memo content limit in bytes reported by
+// the memo settings; longer memos are rejected like Memos does
+func (s *Server) SetContentLengthLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contentLimit = limit
+}
+
+// FailDisplayTime makes the next n display time patches fail with a server
+// error, e.g. to check that memos are never left with the wrong date
+func (s *Server) FailDisplayTime(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.displayTimeFailures = n
+}
+
+// FailDeletes makes the next n memo deletions fail with a server error
+func (s *Server) FailDeletes(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteFailures = n
+}
+
+// FailPages makes creating memos of the given Notion pages fail with a
+// server error, recognized by the page ID in the memo's source marker
+func (s *Server) FailPages(pageIDs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failingPages = pageIDs
+}
+
+// RecordTo makes the server write all recorded calls and memos to path
+// after every Memos write
+func (s *Server) RecordTo(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordPath = path
+}
+
+// Calls returns the recorded Memos API calls
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Memos returns copies of the memos created so far
+func (s *Server) Memos() []Memo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	memos := make([]Memo, len(s.memos))
+	for i, memo := range s.memos {
+		memos[i] = *memo
+	}
+	return memos
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// routes registers the Notion, Memos and inspection endpoints
+func (s *Server) routes() {
+	// Notion
+	s.mux.HandleFunc("POST /v1/search", s.handleSearch)
+	s.mux.HandleFunc("GET /v1/pages/{id}", s.handleRetrievePage)
+	s.mux.HandleFunc("GET /v1/blocks/{id}", s.handleRetrieveBlock)
+	s.mux.HandleFunc("GET /v1/blocks/{id}/children", s.handleBlockChildren)
+	s.mux.HandleFunc("GET /v1/databases/{id}", s.handleRetrieveDatabase)
+	s.mux.HandleFunc("GET /v1/data_sources/{id}", s.handleRetrieveDataSource)
+	s.mux.HandleFunc("POST /v1/data_sources/{id}/query", s.handleQueryDataSource)
+	s.mux.HandleFunc("GET /v1/users/{id}", s.handleRetrieveUser)
+
+	// Memos
+	s.mux.HandleFunc("GET /api/v1/workspace/profile", s.handleWorkspaceProfile)
+	s.mux.HandleFunc("GET /api/v1/memos", s.handleListMemos)
+	s.mux.HandleFunc("POST /api/v1/memos", s.handleCreateMemo)
+	s.mux.HandleFunc("PATCH /api/v1/memos/{id}", s.handleUpdateMemo)
+	s.mux.HandleFunc("DELETE /api/v1/memos/{id}", s.handleDeleteMemo)
+	s.mux.HandleFunc("PATCH /api/v1/memos/{id}/relations", s.handleSetMemoRelations)
+	s.mux.HandleFunc("POST /api/v1/attachments", s.handleCreateAttachment)
+	s.mux.HandleFunc("GET /api/v1/attachments", s.handleListAttachments)
+	s.mux.HandleFunc("DELETE /api/v1/attachments/{id}", s.handleDeleteAttachment)
+	s.mux.HandleFunc("GET /api/v1/memos/{id}/attachments", s.handleListMemoAttachments)
+	s.mux.HandleFunc("PATCH /api/v1/memos/{id}/attachments", s.handleSetMemoAttachments)
+	s.mux.HandleFunc("GET /api/v1/workspace/settings/STORAGE", s.handleStorageSetting)
+	s.mux.HandleFunc("GET /api/v1/workspace/settings/MEMO_RELATED", s.handleMemoRelatedSetting)
+
+	// Inspection
+	s.mux.HandleFunc("GET /_fake/calls", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Calls())
+	})
+	s.mux.HandleFunc("GET /_fake/memos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Memos())
+	})
+}
+
+// handleWorkspaceProfile reports the Memos version
+func (s *Server) handleWorkspaceProfile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	version := s.memosVersion
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"owner":   "users/1",
+		"version": version,
+		"mode":    "prod",
+	})
+}
+
+// handleMemoRelatedSetting reports the workspace memo setting with the
+// content length limit
+func (s *Server) handleMemoRelatedSetting(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	limit := s.contentLimit
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name": "workspace/settings/MEMO_RELATED",
+		"memoRelatedSetting": map[string]interface{}{
+			"contentLengthLimit": limit,
+		},
+	})
+}
+
+// handleListMemos lists the memos in pages; the page token is the offset of
+// the next page. Filters are not evaluated.
+func (s *Server) handleListMemos(w http.ResponseWriter, r *http.Request) {
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+
+	s.mu.Lock()
+	end := min(offset+pageSize, len(s.memos))
+	page := []Memo{}
+	for _, memo := range s.memos[min(offset, end):end] {
+		page = append(page, *memo)
+	}
+	nextPageToken := ""
+	if end < len(s.memos) {
+		nextPageToken = strconv.Itoa(end)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"memos":         page,
+		"nextPageToken": nextPageToken,
+	})
+}
+
+// handleCreateMemo creates a memo and records the call. Like Memos 0.25, the
+// memoId parameter chooses the ID.
+func (s *Server) handleCreateMemo(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.record(r, body, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	if limit := s.contentLimit; len(req.Content) > limit {
+		s.mu.Unlock()
+		s.rejectContent(w, r, body, limit)
+		return
+	}
+	for _, pageID := range s.failingPages {
+		if strings.Contains(req.Content, "page="+pageID+" ") {
+			s.mu.Unlock()
+			s.record(r, body, http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, "internal", "injected failure for page "+pageID)
+			return
+		}
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	id := r.URL.Query().Get("memoId")
+	if id == "" {
+		id = fmt.Sprintf("%d", s.nextMemoID)
+		s.nextMemoID++
+	} else if s.findMemo("memos/"+id) != nil {
+		s.mu.Unlock()
+		s.record(r, body, http.StatusConflict)
+		writeError(w, http.StatusConflict, "already_exists", "memo already exists")
+		return
+	}
+	memo := &Memo{
+		Name:        "memos/" + id,
+		UID:         id,
+		Creator:     "users/1",
+		CreateTime:  now,
+		UpdateTime:  now,
+		DisplayTime: now,
+		Content:     req.Content,
+	}
+	s.memos = append(s.memos, memo)
+	created := *memo
+	s.mu.Unlock()
+
+	s.record(r, body, http.StatusOK)
+	writeJSON(w, http.StatusOK, created)
+}
+
+// handleUpdateMemo applies a patch to a memo and records the call
+func (s *Server) handleUpdateMemo(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var patch struct {
+		Content     *string `json:"content"`
+		DisplayTime *string `json:"displayTime"`
+	}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		s.record(r, body, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	if patch.DisplayTime != nil && s.displayTimeFailures > 0 {
+		s.displayTimeFailures--
+		s.mu.Unlock()
+		s.record(r, body, http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, "internal", "injected display time failure")
+		return
+	}
+	memo := s.findMemo("memos/" + r.PathValue("id"))
+	if memo == nil {
+		s.mu.Unlock()
+		s.record(r, body, http.StatusNotFound)
+		writeError(w, http.StatusNotFound, "not_found", "memo not found")
+		return
+	}
+	if patch.Content != nil {
+		if limit := s.contentLimit; len(*patch.Content) > limit {
+			s.mu.Unlock()
+			s.rejectContent(w, r, body, limit)
+			return
+		}
+		memo.Content = *patch.Content
+	}
+	if patch.DisplayTime != nil {
+		memo.DisplayTime = *patch.DisplayTime
+	}
+	memo.UpdateTime = time.Now().UTC().Format(time.RFC3339)
+	updated := *memo
+	s.mu.Unlock()
+
+	s.record(r, body, http.StatusOK)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// rejectContent answers a write with content over the length limit the way
+// Memos does, which counts bytes despite the message
+func (s *Server) rejectContent(w http.ResponseWriter, r *http.Request, body []byte, limit int) {
+	s.record(r, body, http.StatusBadRequest)
+	writeError(w, http.StatusBadRequest, "invalid_argument", fmt.Sprintf("content too long (max %d characters)", limit))
+}
+
+// handleDeleteMemo deletes a memo and records the call
+func (s *Server) handleDeleteMemo(w http.ResponseWriter, r *http.Request) {
+	name := "memos/" + r.PathValue("id")
+
+	s.mu.Lock()
+	if s.deleteFailures > 0 {
+		s.deleteFailures--
+		s.mu.Unlock()
+		s.record(r, nil, http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, "internal", "injected delete failure")
+		return
+	}
+	found := false
+	for i, memo := range s.memos {
+		if memo.Name == name {
+			s.memos = append(s.memos[:i], s.memos[i+1:]...)
+			found = true
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if !found {
+		s.record(r, nil, http.StatusNotFound)
+		writeError(w, http.StatusNotFound, "not_found", "memo not found")
+		return
+	}
+	s.record(r, nil, http.StatusOK)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// handleSetMemoRelations replaces the references of a memo
+func (s *Server) handleSetMemoRelations(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	var req struct {
+		Relations []struct {
+			RelatedMemo struct {
+				Name string `json:"name"`
+			} `json:"relatedMemo"`
+		} `json:"relations"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.record(r, body, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	memo := s.findMemo("memos/" + r.PathValue("id"))
+	if memo == nil {
+		s.mu.Unlock()
+		s.record(r, body, http.StatusNotFound)
+		writeError(w, http.StatusNotFound, "not_found", "memo not found")
+		return
+	}
+	memo.Relations = nil
+	for _, relation := range req.Relations {
+		memo.Relations = append(memo.Relations, relation.RelatedMemo.Name)
+	}
+	s.mu.Unlock()
+
+	s.record(r, body, http.StatusOK)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// findMemo returns the memo with the given name. Callers must hold s.mu.
+func (s *Server) findMemo(name string) *Memo {
+	for _, memo := range s.memos {
+		if memo.Name == name {
+			return memo
+		}
+	}
+	return nil
+}
+
+// record appends a Memos call and writes the recording file if configured
+func (s *Server) record(r *http.Request, body []byte, status int) {
+	call := Call{
+		Time:   time.Now().UTC(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Token:  strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+		Status: status,
+	}
+	if json.Valid(body) {
+		call.Body = body
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+
+	if s.recordPath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(struct {
+		Calls []Call  `json:"calls"`
+		Memos []*Memo `json:"memos"`
+	}{s.calls, s.memos}, "", "  ")
+	if err == nil {
+		os.WriteFile(s.recordPath, data, 0644)
+	}
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the shape of Notion's error responses
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"object":  "error",
+		"status":  status,
+		"code":    code,
+		"message": message,
+	})
+}
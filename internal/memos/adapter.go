@@ -0,0 +1,288 @@
+package memos
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiAdapter translates client operations into the requests of one Memos
+// API generation. Memos are always identified as "memos/{id}".
+type apiAdapter interface {
+	name() string
+	// createMemo creates a memo and returns its name. A non-empty id is
+	// used as the memo ID (see supportsMemoIDs).
+	createMemo(ctx context.Context, c *Client, id, content string) (string, error)
+	// setDisplayTime sets the time a memo is shown at
+	setDisplayTime(ctx context.Context, c *Client, name string, t time.Time) error
+	updateContent(ctx context.Context, c *Client, name, content string) error
+	deleteMemo(ctx context.Context, c *Client, name string) error
+	// listMemos returns one page of memos and the token of the next page
+	// (empty after the last page)
+	listMemos(ctx context.Context, c *Client, opts ListMemosOptions, pageToken string) ([]Memo, string, error)
+	// setRelations makes name reference the related memos
+	setRelations(ctx context.Context, c *Client, name string, related []string) error
+	// registerTags adds tags to the server's tag list
+	registerTags(ctx context.Context, c *Client, tags []string) error
+	// supportsMemoIDs reports whether clients can choose the memo ID
+	supportsMemoIDs() bool
+	// attachmentKind is the attachment collection ("attachments" or
+	// "resources"), empty if uploads aren't supported
+	attachmentKind() string
+	// settingsPath is the path of a workspace setting, empty if the server
+	// has no settings API
+	settingsPath(setting string) string
+}
+
+// v1Adapter talks to the resource-oriented v1 API of Memos 0.22 and later
+type v1Adapter struct {
+	minor    int
+	settings string // "workspace" or "instance"
+}
+
+func newV1Adapter(minor int, settings string) v1Adapter {
+	return v1Adapter{minor: minor, settings: settings}
+}
+
+func (a v1Adapter) name() string {
+	return "v1 with " + a.attachmentKind()
+}
+
+// createMemo creates the memo; the create request has no time field, so the
+// display time is set separately
+func (a v1Adapter) createMemo(ctx context.Context, c *Client, id, content string) (string, error) {
+	path := "/api/v1/memos"
+	if id != "" {
+		if !a.supportsMemoIDs() {
+			return "", fmt.Errorf("%w: choosing memo IDs requires Memos 0.25 or later", ErrUnsupportedServer)
+		}
+		path += "?memoId=" + url.QueryEscape(id)
+	}
+
+	var memoResp CreateMemoResponse
+	if err := c.doJSON(ctx, "POST", path, CreateMemoRequest{Content: content}, &memoResp); err != nil {
+		return "", err
+	}
+	return memoResp.Name, nil
+}
+
+func (a v1Adapter) setDisplayTime(ctx context.Context, c *Client, name string, t time.Time) error {
+	return c.doJSON(ctx, "PATCH", "/api/v1/"+name, UpdateMemoRequest{DisplayTime: t.Format(time.RFC3339)}, nil)
+}
+
+func (a v1Adapter) updateContent(ctx context.Context, c *Client, name, content string) error {
+	return c.doJSON(ctx, "PATCH", "/api/v1/"+name, UpdateMemoRequest{Content: content}, nil)
+}
+
+func (a v1Adapter) deleteMemo(ctx context.Context, c *Client, name string) error {
+	return c.doJSON(ctx, "DELETE", "/api/v1/"+name, nil, nil)
+}
+
+func (a v1Adapter) listMemos(ctx context.Context, c *Client, opts ListMemosOptions, pageToken string) ([]Memo, string, error) {
+	query := url.Values{"pageSize": {strconv.Itoa(opts.PageSize)}}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+	if opts.Filter != "" {
+		query.Set("filter", opts.Filter)
+	}
+
+	var list struct {
+		Memos         []CreateMemoResponse `json:"memos"`
+		NextPageToken string               `json:"nextPageToken"`
+	}
+	if err := c.doJSON(ctx, "GET", "/api/v1/memos?"+query.Encode(), nil, &list); err != nil {
+		return nil, "", err
+	}
+
+	memos := make([]Memo, len(list.Memos))
+	for i, memo := range list.Memos {
+		displayTime, _ := time.Parse(time.RFC3339, memo.DisplayTime)
+		memos[i] = Memo{Name: memo.Name, Content: memo.Content, DisplayTime: displayTime}
+	}
+	return memos, list.NextPageToken, nil
+}
+
+// setRelations replaces the relations of the memo. Memos 0.22 refers to
+// memos by name, later versions by memo objects.
+func (a v1Adapter) setRelations(ctx context.Context, c *Client, name string, related []string) error {
+	relations := make([]any, len(related))
+	for i, relatedName := range related {
+		if a.minor < 23 {
+			relations[i] = map[string]string{"memo": name, "relatedMemo": relatedName, "type": RelationReference}
+		} else {
+			relations[i] = MemoRelation{
+				Memo:        MemoRef{Name: name},
+				RelatedMemo: MemoRef{Name: relatedName},
+				Type:        RelationReference,
+			}
+		}
+	}
+
+	body := map[string]any{"name": name, "relations": relations}
+	return c.doJSON(ctx, "PATCH", "/api/v1/"+name+"/relations", body, nil)
+}
+
+// registerTags: the tag list is derived from the "#tag" tokens in the content
+func (a v1Adapter) registerTags(context.Context, *Client, []string) error {
+	return nil
+}
+
+// supportsMemoIDs: the memoId parameter was added in Memos 0.25
+func (a v1Adapter) supportsMemoIDs() bool {
+	return a.minor >= 25
+}
+
+// attachmentKind: resources were renamed to attachments in Memos 0.25
+func (a v1Adapter) attachmentKind() string {
+	if a.minor < 25 {
+		return "resources"
+	}
+	return "attachments"
+}
+
+func (a v1Adapter) settingsPath(setting string) string {
+	return "/api/v1/" + a.settings + "/settings/" + setting
+}
+
+// legacyAdapter talks to the REST API of Memos 0.18, which uses numeric IDs
+// and keeps its tag list separately from the content
+type legacyAdapter struct{}
+
+func (legacyAdapter) name() string {
+	return "legacy v1"
+}
+
+// legacyMemo is a memo of the legacy API
+type legacyMemo struct {
+	ID        int    `json:"id"`
+	Content   string `json:"content"`
+	CreatedTs int64  `json:"createdTs"`
+}
+
+// createMemo creates the memo
+func (a legacyAdapter) createMemo(ctx context.Context, c *Client, id, content string) (string, error) {
+	if id != "" {
+		return "", fmt.Errorf("%w: choosing memo IDs requires Memos 0.25 or later", ErrUnsupportedServer)
+	}
+
+	var memo legacyMemo
+	body := map[string]any{"content": content, "visibility": "PRIVATE"}
+	if err := c.doJSON(ctx, "POST", "/api/v1/memo", body, &memo); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("memos/%d", memo.ID), nil
+}
+
+// setDisplayTime sets the creation time, which the legacy API shows memos at
+func (a legacyAdapter) setDisplayTime(ctx context.Context, c *Client, name string, t time.Time) error {
+	id, err := legacyID(name)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, "PATCH", fmt.Sprintf("/api/v1/memo/%d", id), map[string]any{"createdTs": t.Unix()}, nil)
+}
+
+func (a legacyAdapter) updateContent(ctx context.Context, c *Client, name, content string) error {
+	id, err := legacyID(name)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, "PATCH", fmt.Sprintf("/api/v1/memo/%d", id), map[string]any{"content": content}, nil)
+}
+
+func (a legacyAdapter) deleteMemo(ctx context.Context, c *Client, name string) error {
+	id, err := legacyID(name)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, "DELETE", fmt.Sprintf("/api/v1/memo/%d", id), nil, nil)
+}
+
+// listMemos pages through the memos by offset; the token is the offset of
+// the next page
+func (a legacyAdapter) listMemos(ctx context.Context, c *Client, opts ListMemosOptions, pageToken string) ([]Memo, string, error) {
+	offset := 0
+	if pageToken != "" {
+		var err error
+		if offset, err = strconv.Atoi(pageToken); err != nil {
+			return nil, "", fmt.Errorf("invalid page token %q", pageToken)
+		}
+	}
+
+	var list []legacyMemo
+	path := fmt.Sprintf("/api/v1/memo?limit=%d&offset=%d", opts.PageSize, offset)
+	if err := c.doJSON(ctx, "GET", path, nil, &list); err != nil {
+		return nil, "", err
+	}
+
+	memos := make([]Memo, len(list))
+	for i, memo := range list {
+		memos[i] = Memo{
+			Name:        fmt.Sprintf("memos/%d", memo.ID),
+			Content:     memo.Content,
+			DisplayTime: time.Unix(memo.CreatedTs, 0).UTC(),
+		}
+	}
+	if len(list) < opts.PageSize {
+		return memos, "", nil
+	}
+	return memos, strconv.Itoa(offset + len(list)), nil
+}
+
+// setRelations adds the references; the legacy API has no way to replace
+// all relations at once
+func (a legacyAdapter) setRelations(ctx context.Context, c *Client, name string, related []string) error {
+	id, err := legacyID(name)
+	if err != nil {
+		return err
+	}
+	for _, relatedName := range related {
+		relatedID, err := legacyID(relatedName)
+		if err != nil {
+			return err
+		}
+		body := map[string]any{"relatedMemoId": relatedID, "type": RelationReference}
+		if err := c.doJSON(ctx, "POST", fmt.Sprintf("/api/v1/memo/%d/relation", id), body, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (legacyAdapter) supportsMemoIDs() bool {
+	return false
+}
+
+// attachmentKind: legacy resources are uploaded as multipart blobs, which
+// the client doesn't support
+func (legacyAdapter) attachmentKind() string {
+	return ""
+}
+
+func (legacyAdapter) settingsPath(string) string {
+	return ""
+}
+
+// registerTags creates the tags, which the legacy API doesn't derive from
+// the content
+func (legacyAdapter) registerTags(ctx context.Context, c *Client, tags []string) error {
+	for _, tag := range tags {
+		if err := c.doJSON(ctx, "POST", "/api/v1/tag", map[string]any{"name": tag}, nil); err != nil {
+			return fmt.Errorf("failed to create tag %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// legacyID extracts the numeric ID from a "memos/{id}" name
+func legacyID(name string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(name, "memos/"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid memo name %q", name)
+	}
+	return id, nil
+}
@@ -0,0 +1,297 @@
+package memos
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// defaultUploadSizeLimitMB is the limit Memos applies when none is configured
+const defaultUploadSizeLimitMB = 32
+
+// Attachment is a file attached to a memo. Memos before v0.25 call them
+// resources; both shapes decode into this type.
+type Attachment struct {
+	Name         string `json:"name"`
+	CreateTime   string `json:"createTime,omitempty"`
+	Filename     string `json:"filename"`
+	ExternalLink string `json:"externalLink,omitempty"`
+	Type         string `json:"type"`
+	Size         int64  `json:"size,omitempty"`
+	Memo         string `json:"memo,omitempty"`
+}
+
+// UnmarshalJSON decodes the size, which the API encodes as a string
+func (a *Attachment) UnmarshalJSON(data []byte) error {
+	type attachment Attachment
+	aux := struct {
+		*attachment
+		Size json.Number `json:"size"`
+	}{attachment: (*attachment)(a)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Size != "" {
+		size, err := aux.Size.Int64()
+		if err != nil {
+			return fmt.Errorf("invalid attachment size %q: %w", aux.Size, err)
+		}
+		a.Size = size
+	}
+	return nil
+}
+
+// AttachmentUpload describes a file to upload
+type AttachmentUpload struct {
+	Filename string
+	// Type is the MIME type, e.g. "image/png"
+	Type    string
+	Content io.Reader
+	// Size is the length of Content in bytes, or 0 if unknown. Known sizes are
+	// checked against the server's limit before anything is sent; unknown
+	// sizes are checked while streaming.
+	Size int64
+	// Memo optionally links the attachment to a memo ("memos/{id}")
+	Memo string
+}
+
+// attachmentList is the list response of either API shape
+type attachmentList struct {
+	Attachments   []Attachment `json:"attachments"`
+	Resources     []Attachment `json:"resources"`
+	NextPageToken string       `json:"nextPageToken"`
+}
+
+// attachmentAPI caches the upload limit of the server
+type attachmentAPI struct {
+	mu          sync.Mutex
+	uploadLimit int64 // bytes, 0 until retrieved
+}
+
+// attachmentKind returns the collection name of the server's attachment
+// API: "attachments" since Memos v0.25, "resources" before
+func (c *Client) attachmentKind(ctx context.Context) (string, error) {
+	api, err := c.adapter(ctx)
+	if err != nil {
+		return "", err
+	}
+	if api.attachmentKind() == "" {
+		return "", fmt.Errorf("%w: attachments require Memos 0.22 or later", ErrUnsupportedServer)
+	}
+	return api.attachmentKind(), nil
+}
+
+// UploadSizeLimit returns the server's upload size limit in bytes. Servers
+// that don't expose their storage settings to the token are assumed to use
+// the Memos default of 32 MiB.
+func (c *Client) UploadSizeLimit(ctx context.Context) (int64, error) {
+	api, err := c.adapter(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	c.attachments.mu.Lock()
+	defer c.attachments.mu.Unlock()
+
+	if c.attachments.uploadLimit > 0 {
+		return c.attachments.uploadLimit, nil
+	}
+
+	var setting struct {
+		StorageSetting struct {
+			UploadSizeLimitMB json.Number `json:"uploadSizeLimitMb"`
+		} `json:"storageSetting"`
+	}
+	limitMB := int64(defaultUploadSizeLimitMB)
+	path := api.settingsPath("STORAGE")
+	if path != "" {
+		err = c.doJSON(ctx, "GET", path, nil, &setting)
+	}
+	switch {
+	case path == "":
+		// No settings API; keep the default
+	case err == nil:
+		if mb, err := setting.StorageSetting.UploadSizeLimitMB.Int64(); err == nil && mb > 0 {
+			limitMB = mb
+		}
+	case isStatus(err, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound):
+		// Keep the default
+	default:
+		return 0, fmt.Errorf("failed to retrieve storage settings: %w", err)
+	}
+
+	c.attachments.uploadLimit = limitMB << 20
+	return c.attachments.uploadLimit, nil
+}
+
+// UploadAttachment uploads a file. The content is base64-encoded into the
+// request body while it is being sent, so large files are never held in memory.
+func (c *Client) UploadAttachment(ctx context.Context, upload AttachmentUpload) (*Attachment, error) {
+	if upload.Filename == "" {
+		return nil, fmt.Errorf("attachment filename is required")
+	}
+
+	kind, err := c.attachmentKind(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := c.UploadSizeLimit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Size > limit {
+		return nil, fmt.Errorf("%w: %s is %d bytes, limit is %d bytes", ErrAttachmentTooLarge, upload.Filename, upload.Size, limit)
+	}
+
+	body, writer := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeUploadBody(writer, upload, limit)
+		writer.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/v1/"+kind, body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// The client timeout would cut off large uploads
+	var attachment Attachment
+	err = c.do(c.uploadClient, req, &attachment)
+	body.Close()
+
+	// A rejected request closes the pipe under the writer, so its error only
+	// matters if the limit was hit or the request itself succeeded
+	werr := <-writeErr
+	if errors.Is(werr, ErrAttachmentTooLarge) || (err == nil && werr != nil) {
+		err = werr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", upload.Filename, err)
+	}
+	return &attachment, nil
+}
+
+// writeUploadBody writes the create request as JSON, streaming the content
+// through a base64 encoder
+func writeUploadBody(w io.Writer, upload AttachmentUpload, limit int64) error {
+	header, err := json.Marshal(struct {
+		Filename string `json:"filename"`
+		Type     string `json:"type,omitempty"`
+		Memo     string `json:"memo,omitempty"`
+	}{upload.Filename, upload.Type, upload.Memo})
+	if err != nil {
+		return err
+	}
+
+	// Reopen the object to append the content field
+	if _, err := w.Write(header[:len(header)-1]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"content":"`); err != nil {
+		return err
+	}
+
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := io.Copy(enc, &limitedReader{r: upload.Content, remaining: limit}); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, `"}`)
+	return err
+}
+
+// limitedReader fails with ErrAttachmentTooLarge once more than remaining
+// bytes were read
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrAttachmentTooLarge
+	}
+	return n, err
+}
+
+// SetMemoAttachments replaces the attachments linked to a memo
+func (c *Client) SetMemoAttachments(ctx context.Context, memoName string, attachmentNames []string) error {
+	kind, err := c.attachmentKind(ctx)
+	if err != nil {
+		return err
+	}
+
+	refs := make([]map[string]string, len(attachmentNames))
+	for i, name := range attachmentNames {
+		refs[i] = map[string]string{"name": name}
+	}
+	body := map[string]any{"name": memoName, kind: refs}
+
+	if err := c.doJSON(ctx, "PATCH", "/api/v1/"+memoName+"/"+kind, body, nil); err != nil {
+		return fmt.Errorf("failed to set attachments of %s: %w", memoName, err)
+	}
+	return nil
+}
+
+// ListAttachments lists the attachments of a memo, or all attachments of
+// the user if memoName is empty
+func (c *Client) ListAttachments(ctx context.Context, memoName string) ([]Attachment, error) {
+	kind, err := c.attachmentKind(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if memoName != "" {
+		var list attachmentList
+		if err := c.doJSON(ctx, "GET", "/api/v1/"+memoName+"/"+kind, nil, &list); err != nil {
+			return nil, fmt.Errorf("failed to list attachments of %s: %w", memoName, err)
+		}
+		return append(list.Attachments, list.Resources...), nil
+	}
+
+	var attachments []Attachment
+	pageToken := ""
+	for {
+		path := "/api/v1/" + kind + "?pageSize=100"
+		if pageToken != "" {
+			path += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+
+		var list attachmentList
+		if err := c.doJSON(ctx, "GET", path, nil, &list); err != nil {
+			return nil, fmt.Errorf("failed to list attachments: %w", err)
+		}
+		attachments = append(attachments, list.Attachments...)
+		attachments = append(attachments, list.Resources...)
+
+		// The resources API returns everything at once without a token
+		if list.NextPageToken == "" {
+			return attachments, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// DeleteAttachment deletes an attachment by name ("attachments/{id}" or
+// "resources/{id}")
+func (c *Client) DeleteAttachment(ctx context.Context, name string) error {
+	if err := c.doJSON(ctx, "DELETE", "/api/v1/"+name, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", name, err)
+	}
+	return nil
+}
@@ -2,31 +2,66 @@ package memos
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Client is a Memos API client
 type Client struct {
-	baseURL    string
-	token      string
-	httpClient *http.Client
+	baseURL      string
+	token        string
+	httpClient   *http.Client
+	uploadClient *http.Client // httpClient without timeout; uploads are bounded by their context
+
+	attachments attachmentAPI
+
+	serverMu sync.Mutex
+	server   *ServerInfo // detected on first use
+	api      apiAdapter
+
+	settingsMu   sync.Mutex
+	contentLimit int // bytes, 0 until retrieved
+}
+
+// ClientOptions configures a Memos API client
+type ClientOptions struct {
+	// HTTPClient overrides the HTTP client used for requests
+	HTTPClient *http.Client
 }
 
 // NewClient creates a new Memos API client
-func NewClient(baseURL, token string) *Client {
+func NewClient(baseURL, token string, opts ClientOptions) *Client {
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	uploadClient := *httpClient
+	uploadClient.Timeout = 0
+
 	return &Client{
-		baseURL:    baseURL,
-		token:      token,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		token:        token,
+		httpClient:   httpClient,
+		uploadClient: &uploadClient,
 	}
 }
 
+// Retries of the display time patch after a memo was created
+const (
+	displayTimeAttempts   = 4
+	displayTimeRetryDelay = time.Second
+)
+
 // CreateMemoRequest represents the request to create a memo
 type CreateMemoRequest struct {
 	Content string `json:"content"`
@@ -34,6 +69,7 @@ type CreateMemoRequest struct {
 
 // UpdateMemoRequest represents the request to update memo fields
 type UpdateMemoRequest struct {
+	Content     string `json:"content,omitempty"`
 	DisplayTime string `json:"displayTime,omitempty"`
 }
 
@@ -47,84 +83,198 @@ type CreateMemoResponse struct {
 	Content     string `json:"content"`
 }
 
-// CreateMemo creates a new memo in Memos
-func (c *Client) CreateMemo(content string, createdTime time.Time, dryRun bool) error {
-	if dryRun {
-		return c.saveDryRunMemo(content, createdTime)
-	}
+// CreateMemo creates a new memo in Memos and returns its name ("memos/{id}").
+// In dry-run mode the memo is written to a file and the name is empty.
+func (c *Client) CreateMemo(ctx context.Context, content string, createdTime time.Time, dryRun bool) (string, error) {
+	return c.CreateMemoWithID(ctx, "", content, createdTime, dryRun)
+}
 
-	// Step 1: Create the memo
-	req := CreateMemoRequest{
-		Content: content,
+// CreateMemoWithID creates a memo named "memos/{id}" (Memos 0.25 and later),
+// or a memo with a server-assigned ID if id is empty. If a memo with the ID
+// exists, the error matches ErrAlreadyExists.
+func (c *Client) CreateMemoWithID(ctx context.Context, id, content string, createdTime time.Time, dryRun bool) (string, error) {
+	if dryRun {
+		return "", c.saveDryRunMemo(content, createdTime)
 	}
 
-	body, err := json.Marshal(req)
+	api, err := c.adapter(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return "", err
 	}
-
-	httpReq, err := http.NewRequest("POST", c.baseURL+"/api/v1/memos", bytes.NewReader(body))
+	name, err := api.createMemo(ctx, c, id, content)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create memo: %w", err)
 	}
 
-	httpReq.Header.Set("Authorization", "Bearer "+c.token)
-	httpReq.Header.Set("Content-Type", "application/json")
+	// Creation and display time are one unit: a memo showing today's date
+	// is removed again rather than left behind
+	err = c.setDisplayTime(ctx, api, name, createdTime)
+	if err == nil {
+		return name, nil
+	}
+	if delErr := api.deleteMemo(ctx, c, name); delErr != nil {
+		return "", &IncompleteMemoError{Name: name, DisplayTime: createdTime, Err: err}
+	}
+	return "", fmt.Errorf("created memo was deleted again: %w", err)
+}
 
-	resp, err := c.httpClient.Do(httpReq)
+// RegisterTags adds tags to the server's tag list. Only Memos 0.18 keeps a
+// separate list; later versions list the "#tag" tokens of the memos' content
+// on their own, so nothing is sent to them.
+func (c *Client) RegisterTags(ctx context.Context, tags []string) error {
+	api, err := c.adapter(ctx)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+	var distinct []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(tag, "#")
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			distinct = append(distinct, tag)
+		}
 	}
+	return api.registerTags(ctx, c, distinct)
+}
 
-	// Parse the response to get the memo name (ID)
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+// tagPattern matches "#tag" tokens; headings ("# Title") don't match
+var tagPattern = regexp.MustCompile(`(?:^|\s)#([^\s#]+)`)
+
+// ContentTags returns the distinct "#tag" tokens of a memo's content without
+// the "#", in order of appearance. Like in Memos, tokens in code blocks and
+// code spans aren't tags.
+func ContentTags(content string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, match := range tagPattern.FindAllStringSubmatch(withoutCode(content), -1) {
+		if tag := match[1]; !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
 	}
+	return tags
+}
 
-	var memoResp CreateMemoResponse
-	if err := json.Unmarshal(bodyBytes, &memoResp); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+// withoutCode blanks out the code blocks and code spans of Markdown content.
+// Fences are runs of at least three backticks, possibly indented in lists,
+// closed by a run at least as long; a code span is closed by a run of the
+// same length it was opened with.
+func withoutCode(content string) string {
+	var out strings.Builder
+	fence := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		rest := strings.TrimLeft(trimmed, "`")
+		ticks := len(trimmed) - len(rest)
+		switch {
+		case fence > 0:
+			if ticks >= fence && strings.TrimSpace(rest) == "" {
+				fence = 0
+			}
+			out.WriteString("\n")
+		case ticks >= 3:
+			fence = ticks
+			out.WriteString("\n")
+		default:
+			out.WriteString(withoutCodeSpans(line))
+		}
 	}
+	return out.String()
+}
 
-	// Step 2: Update the displayTime via PATCH
-	displayTime := createdTime.Format(time.RFC3339)
-	updateReq := UpdateMemoRequest{
-		DisplayTime: displayTime,
+// withoutCodeSpans replaces the code spans of a line with spaces. Backticks
+// that are never closed are kept as they are.
+func withoutCodeSpans(line string) string {
+	var out strings.Builder
+	for {
+		start := strings.Index(line, "`")
+		if start < 0 {
+			break
+		}
+		n := len(line[start:]) - len(strings.TrimLeft(line[start:], "`"))
+		end := closingBackticks(line[start+n:], n)
+		if end < 0 {
+			out.WriteString(line[:start+n])
+			line = line[start+n:]
+			continue
+		}
+		out.WriteString(line[:start])
+		out.WriteString(" ")
+		line = line[start+n+end+n:]
 	}
+	out.WriteString(line)
+	return out.String()
+}
 
-	updateBody, err := json.Marshal(updateReq)
-	if err != nil {
-		return fmt.Errorf("failed to marshal update request: %w", err)
+// closingBackticks returns the index of the first run of exactly n backticks
+// in s, or -1 if there is none
+func closingBackticks(s string, n int) int {
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		run := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+		if run == n {
+			return i
+		}
+		i += run
 	}
+	return -1
+}
 
-	// PATCH request to update displayTime
-	patchURL := fmt.Sprintf("%s/api/v1/%s", c.baseURL, memoResp.Name)
-	patchReq, err := http.NewRequest("PATCH", patchURL, bytes.NewReader(updateBody))
+// SetDisplayTime sets the time a memo is shown at, retrying transient failures
+func (c *Client) SetDisplayTime(ctx context.Context, name string, t time.Time) error {
+	api, err := c.adapter(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create patch request: %w", err)
+		return err
 	}
+	return c.setDisplayTime(ctx, api, name, t)
+}
 
-	patchReq.Header.Set("Authorization", "Bearer "+c.token)
-	patchReq.Header.Set("Content-Type", "application/json")
+func (c *Client) setDisplayTime(ctx context.Context, api apiAdapter, name string, t time.Time) error {
+	delay := displayTimeRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := api.setDisplayTime(ctx, c, name, t)
+		if err == nil || attempt >= displayTimeAttempts || !isTransient(err) {
+			if err != nil {
+				return fmt.Errorf("failed to set display time of %s: %w", name, err)
+			}
+			return nil
+		}
 
-	patchResp, err := c.httpClient.Do(patchReq)
-	if err != nil {
-		return fmt.Errorf("patch request failed: %w", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
 	}
-	defer patchResp.Body.Close()
+}
 
-	if patchResp.StatusCode < 200 || patchResp.StatusCode >= 300 {
-		patchBodyBytes, _ := io.ReadAll(patchResp.Body)
-		return fmt.Errorf("patch request failed with status %d: %s", patchResp.StatusCode, string(patchBodyBytes))
+// UpdateMemoContent replaces the content of a memo
+func (c *Client) UpdateMemoContent(ctx context.Context, name, content string) error {
+	api, err := c.adapter(ctx)
+	if err != nil {
+		return err
 	}
+	if err := api.updateContent(ctx, c, name, content); err != nil {
+		return fmt.Errorf("failed to update %s: %w", name, err)
+	}
+	return nil
+}
 
+// DeleteMemo deletes a memo
+func (c *Client) DeleteMemo(ctx context.Context, name string) error {
+	api, err := c.adapter(ctx)
+	if err != nil {
+		return err
+	}
+	if err := api.deleteMemo(ctx, c, name); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", name, err)
+	}
 	return nil
 }
 
@@ -152,3 +302,50 @@ func (c *Client) saveDryRunMemo(content string, createdTime time.Time) error {
 
 	return nil
 }
+
+// doJSON sends a request to an API path with body as JSON (if not nil) and
+// decodes the JSON response into out (if not nil)
+func (c *Client) doJSON(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(c.httpClient, req, out)
+}
+
+// do sends an authorized request and decodes the JSON response into out (if
+// not nil). Non-2xx responses are returned as *APIError.
+func (c *Client) do(httpClient *http.Client, req *http.Request, out any) error {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return &APIError{Status: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package memos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContentTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"tags", "#Projects #Roadmap\nShip it #Roadmap", []string{"Projects", "Roadmap"}},
+		{"heading", "# Title\n\nText", nil},
+		{"fenced code", "#Work\n```go\n#notatag\n```\n#Done", []string{"Work", "Done"}},
+		{"indented fence", "- Item\n  ```\n  #notatag\n  ```\n  #Item", []string{"Item"}},
+		{"longer closing fence", "````\n```\n#notatag\n`````\n#after", []string{"after"}},
+		{"unclosed fence", "#before\n```\n#notatag", []string{"before"}},
+		{"code span", "Run `grep #notatag` for #real", []string{"real"}},
+		{"double backtick span", "``a ` #notatag`` #real", []string{"real"}},
+		{"unclosed backtick", "Price `5 #real", []string{"real"}},
+		{"tag after span", "`code` #real", []string{"real"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContentTags(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ContentTags(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package memos
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Sentinel errors; ErrUnauthorized, ErrNotFound and ErrAlreadyExists are
+// matched by APIError through errors.Is
+var (
+	ErrUnauthorized       = errors.New("memos: unauthorized")
+	ErrNotFound           = errors.New("memos: not found")
+	ErrAlreadyExists      = errors.New("memos: already exists")
+	ErrAttachmentTooLarge = errors.New("memos: attachment exceeds the upload size limit")
+)
+
+// APIError is an error response from the Memos API
+type APIError struct {
+	Status int
+	Body   string
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.Status, e.Body)
+}
+
+// Is matches ErrUnauthorized for 401 and 403, ErrNotFound for 404 and
+// ErrAlreadyExists for 409 responses, so callers can use
+// errors.Is(err, memos.ErrNotFound)
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrAlreadyExists:
+		return e.Status == http.StatusConflict
+	}
+	return false
+}
+
+// IncompleteMemoError reports a memo that was created but neither got its
+// display time nor could be deleted again. The caller should record it and
+// set the display time later.
+type IncompleteMemoError struct {
+	Name        string
+	DisplayTime time.Time
+	Err         error
+}
+
+// Error implements error
+func (e *IncompleteMemoError) Error() string {
+	return fmt.Sprintf("memo %s was created without its display time: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the display time error
+func (e *IncompleteMemoError) Unwrap() error {
+	return e.Err
+}
+
+// isTransient reports whether a failed request may succeed when repeated:
+// network errors, throttling and server errors
+func isTransient(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.Status == http.StatusTooManyRequests || apiErr.Status >= 500
+}
+
+// isStatus reports whether err is an APIError with one of the statuses
+func isStatus(err error, statuses ...int) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, status := range statuses {
+		if apiErr.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound reports whether err is a 404 response
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
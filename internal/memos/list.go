@@ -0,0 +1,51 @@
+package memos
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultListPageSize is the number of memos requested per page
+const defaultListPageSize = 200
+
+// Memo is a memo as listed by the server
+type Memo struct {
+	Name        string
+	Content     string
+	DisplayTime time.Time
+}
+
+// ListMemosOptions selects the memos to list
+type ListMemosOptions struct {
+	// Filter is passed to the server as is, e.g. `creator_id == 1` (the
+	// syntax depends on the Memos version; ignored by Memos 0.18)
+	Filter string
+	// PageSize is the number of memos requested at once (default 200)
+	PageSize int
+}
+
+// ListMemos lists the memos visible to the token, following all result pages
+func (c *Client) ListMemos(ctx context.Context, opts ListMemosOptions) ([]Memo, error) {
+	api, err := c.adapter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultListPageSize
+	}
+
+	var memos []Memo
+	pageToken := ""
+	for {
+		page, next, err := api.listMemos(ctx, c, opts, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list memos: %w", err)
+		}
+		memos = append(memos, page...)
+		if next == "" {
+			return memos, nil
+		}
+		pageToken = next
+	}
+}
@@ -0,0 +1,34 @@
+package memos
+
+import (
+	"context"
+	"fmt"
+)
+
+// RelationReference is the relation type shown as "references" in Memos
+const RelationReference = "REFERENCE"
+
+// MemoRelation relates a memo to another memo
+type MemoRelation struct {
+	Memo        MemoRef `json:"memo"`
+	RelatedMemo MemoRef `json:"relatedMemo"`
+	Type        string  `json:"type"`
+}
+
+// MemoRef identifies a memo by name ("memos/{id}")
+type MemoRef struct {
+	Name string `json:"name"`
+}
+
+// SetMemoRelations replaces the relations of a memo with references to the
+// related memos. Memos shows each reference on both memos.
+func (c *Client) SetMemoRelations(ctx context.Context, name string, related []string) error {
+	api, err := c.adapter(ctx)
+	if err != nil {
+		return err
+	}
+	if err := api.setRelations(ctx, c, name, related); err != nil {
+		return fmt.Errorf("failed to set relations of %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package memos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedServer is returned for Memos versions without an API adapter
+var ErrUnsupportedServer = errors.New("memos: unsupported server version")
+
+// ServerInfo describes a Memos server
+type ServerInfo struct {
+	Version string
+	Mode    string
+	// API names the adapter the client talks to the server with
+	API string
+	// MemoIDs is set if clients can choose memo IDs
+	MemoIDs bool
+}
+
+// Content length limits of memos, in bytes (see ContentLength)
+const (
+	// DefaultContentLengthLimit is the limit of Memos 0.22 and later unless
+	// the workspace's memo settings change it
+	DefaultContentLengthLimit = 8 * 1024
+	// legacyContentLengthLimit is the fixed limit of Memos 0.18
+	legacyContentLengthLimit = 1 << 30
+)
+
+// serverProfile is the profile of a server as reported by one of its
+// profile endpoints
+type serverProfile struct {
+	Version string `json:"version"`
+	Mode    string `json:"mode"`
+}
+
+// profileProbes are the endpoints reporting the server version, newest first
+var profileProbes = []struct {
+	path string
+	// settings is the prefix of the workspace settings path ("" if the
+	// version has no settings API)
+	settings string
+	profile  func(body *profileResponse) serverProfile
+}{
+	// v0.26+: the workspace was renamed to instance
+	{"/api/v1/instance/profile", "instance", func(b *profileResponse) serverProfile { return b.serverProfile }},
+	// v0.22 - v0.25
+	{"/api/v1/workspace/profile", "workspace", func(b *profileResponse) serverProfile { return b.serverProfile }},
+	// v0.18 and earlier
+	{"/api/v1/status", "", func(b *profileResponse) serverProfile { return b.Profile }},
+	// v0.19 - v0.21
+	{"/api/v2/workspace/profile", "", func(b *profileResponse) serverProfile { return b.WorkspaceProfile }},
+}
+
+// profileResponse covers the response shapes of all profile endpoints
+type profileResponse struct {
+	serverProfile
+	Profile          serverProfile `json:"profile"`
+	WorkspaceProfile serverProfile `json:"workspaceProfile"`
+}
+
+// ServerInfo detects the server version and the API adapter for it. The
+// result is cached, so later calls don't send requests.
+func (c *Client) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	if _, err := c.adapter(ctx); err != nil {
+		return nil, err
+	}
+	c.serverMu.Lock()
+	defer c.serverMu.Unlock()
+	info := *c.server
+	return &info, nil
+}
+
+// adapter returns the API adapter of the server, detecting it on first use
+func (c *Client) adapter(ctx context.Context) (apiAdapter, error) {
+	c.serverMu.Lock()
+	defer c.serverMu.Unlock()
+
+	if c.api != nil {
+		return c.api, nil
+	}
+
+	info, api, err := c.detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.server, c.api = info, api
+	return api, nil
+}
+
+// detect probes the profile endpoints and picks the adapter for the version
+func (c *Client) detect(ctx context.Context) (*ServerInfo, apiAdapter, error) {
+	for _, probe := range profileProbes {
+		var body profileResponse
+		err := c.doJSON(ctx, "GET", probe.path, nil, &body)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to detect Memos version at %s: %w", c.baseURL, err)
+		}
+
+		profile := probe.profile(&body)
+		api, err := adapterFor(profile.Version, probe.settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		info := &ServerInfo{
+			Version: profile.Version,
+			Mode:    profile.Mode,
+			API:     api.name(),
+			MemoIDs: api.supportsMemoIDs(),
+		}
+		return info, api, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: %s doesn't look like a Memos server (no profile endpoint found)", ErrUnsupportedServer, c.baseURL)
+}
+
+// adapterFor picks the adapter for a server version. settings is the
+// settings path prefix of the profile endpoint that answered; it also
+// identifies the API generation when the version can't be parsed (e.g.
+// development builds).
+func adapterFor(version, settings string) (apiAdapter, error) {
+	minor, ok := parseMinorVersion(version)
+	if !ok {
+		if settings != "" {
+			return newV1Adapter(99, settings), nil
+		}
+		return nil, fmt.Errorf("%w: can't parse version %q", ErrUnsupportedServer, version)
+	}
+
+	switch {
+	case minor < 18:
+		return nil, fmt.Errorf("%w: Memos %s is too old, upgrade to 0.22 or later", ErrUnsupportedServer, version)
+	case minor == 18:
+		return legacyAdapter{}, nil
+	case minor < 22:
+		return nil, fmt.Errorf("%w: Memos %s (the v2 API of 0.19 - 0.21) is not supported, upgrade to 0.22 or later", ErrUnsupportedServer, version)
+	}
+	if settings == "" {
+		settings = "workspace"
+	}
+	return newV1Adapter(minor, settings), nil
+}
+
+// parseMinorVersion returns the minor version of a "0.MINOR.PATCH" version
+// string ("v" prefixes and "-dev" suffixes are ignored)
+func parseMinorVersion(version string) (int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return 0, false
+	}
+	if major, err := strconv.Atoi(parts[0]); err != nil {
+		return 0, false
+	} else if major > 0 {
+		// Versions after 0.x use the newest API
+		return 99, true
+	}
+	minor, err := strconv.Atoi(strings.SplitN(parts[1], "-", 2)[0])
+	if err != nil {
+		return 0, false
+	}
+	return minor, true
+}
+
+// ContentLengthLimit returns the longest memo content the server accepts, as
+// measured by ContentLength. It is read from the workspace's memo settings;
+// servers that don't expose them to the token are assumed to use
+// DefaultContentLengthLimit. The result is cached.
+func (c *Client) ContentLengthLimit(ctx context.Context) (int, error) {
+	api, err := c.adapter(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+
+	if c.contentLimit > 0 {
+		return c.contentLimit, nil
+	}
+
+	var setting struct {
+		MemoRelatedSetting struct {
+			ContentLengthLimit json.Number `json:"contentLengthLimit"`
+		} `json:"memoRelatedSetting"`
+	}
+	limit := DefaultContentLengthLimit
+	path := api.settingsPath("MEMO_RELATED")
+	if path != "" {
+		err = c.doJSON(ctx, "GET", path, nil, &setting)
+	}
+	switch {
+	case path == "":
+		// Memos 0.18 has no settings API and a fixed limit
+		limit = legacyContentLengthLimit
+	case err == nil:
+		// Before the limit became a setting it isn't reported
+		if n, err := setting.MemoRelatedSetting.ContentLengthLimit.Int64(); err == nil && n > 0 {
+			limit = int(n)
+		}
+	case isStatus(err, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound):
+		// Keep the default
+	default:
+		return 0, fmt.Errorf("failed to retrieve memo settings: %w", err)
+	}
+
+	c.contentLimit = limit
+	return c.contentLimit, nil
+}
+
+// ContentLength returns the length of memo content the way Memos checks it
+// against the content length limit: in bytes of UTF-8, so characters outside
+// ASCII count two to four times
+func ContentLength(content string) int {
+	return len(content)
+}
@@ -0,0 +1,175 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/OneManRepo/notion2memos/internal/config"
+	"github.com/OneManRepo/notion2memos/internal/memos"
+	"github.com/OneManRepo/notion2memos/internal/notion"
+)
+
+// authorResolver decides which Memos account creates the memo for a page
+type authorResolver struct {
+	notionClient  *notion.Client
+	memosURL      string
+	memosOpts     memos.ClientOptions
+	mapping       *config.UserMapping
+	defaultClient *memos.Client
+
+	// mu guards the maps, since pages are migrated concurrently
+	mu       sync.Mutex
+	clients  map[string]*memos.Client // keyed by token
+	users    map[string]*notion.User  // Notion users resolved so far
+	unmapped map[string]*unmappedAuthor
+}
+
+// unmappedAuthor records a Notion author that fell back to the default account
+type unmappedAuthor struct {
+	Name  string
+	Email string
+	Pages []string
+}
+
+// newAuthorResolver creates a resolver. Without a mapping every page is
+// created by the default client.
+func newAuthorResolver(notionClient *notion.Client, memosURL string, memosOpts memos.ClientOptions, mapping *config.UserMapping, defaultClient *memos.Client) *authorResolver {
+	r := &authorResolver{
+		notionClient:  notionClient,
+		memosURL:      memosURL,
+		memosOpts:     memosOpts,
+		mapping:       mapping,
+		defaultClient: defaultClient,
+		clients:       make(map[string]*memos.Client),
+		users:         make(map[string]*notion.User),
+		unmapped:      make(map[string]*unmappedAuthor),
+	}
+
+	// A mapping can name its own default account
+	if mapping != nil && mapping.Default.Token != "" {
+		r.defaultClient = r.clientForToken(mapping.Default.Token)
+	}
+
+	return r
+}
+
+// clientFor returns the Memos client for the author of a page
+func (r *authorResolver) clientFor(ctx context.Context, page *notion.Page) *memos.Client {
+	if r.mapping == nil {
+		return r.defaultClient
+	}
+
+	people := page.GetPeople(r.mapping.Property)
+	if len(people) == 0 {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.recordUnmapped("", page)
+		return r.defaultClient
+	}
+
+	// The first person of the property is the author
+	author := r.resolveUser(ctx, people[0])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if account, ok := r.mapping.Lookup(author.ID, author.GetEmail()); ok {
+		return r.clientForToken(account.Token)
+	}
+
+	r.recordUnmapped(author.ID, page)
+	return r.defaultClient
+}
+
+// resolveUser fills in name and email of a user reference through the users
+// API. The lock isn't held during the request, so other pages go on; a user
+// retrieved twice at the same time is stored once. Users that failed to be
+// retrieved for a passing reason (rate limits, server errors, cancellation)
+// aren't stored, so the next page of the author tries again.
+func (r *authorResolver) resolveUser(ctx context.Context, ref notion.User) *notion.User {
+	r.mu.Lock()
+	cached, ok := r.users[ref.ID]
+	r.mu.Unlock()
+	if ok {
+		return cached
+	}
+
+	user := &ref
+	if ref.GetEmail() == "" {
+		full, err := r.notionClient.RetrieveUser(ctx, ref.ID)
+		switch {
+		case err == nil:
+			user = full
+		case errors.Is(err, notion.ErrObjectNotFound), errors.Is(err, notion.ErrRestrictedResource):
+			// Bots and users outside the workspace can't be retrieved; the
+			// ID alone may still be mapped
+			log.Printf("Warning: failed to retrieve Notion user %s: %v\n", ref.ID, err)
+		default:
+			log.Printf("Warning: failed to retrieve Notion user %s, trying again with their next page: %v\n", ref.ID, err)
+			return user
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, ok := r.users[ref.ID]; ok {
+		return cached
+	}
+	r.users[ref.ID] = user
+	return user
+}
+
+// clientForToken returns a Memos client acting with the given token. The
+// caller holds r.mu, except in newAuthorResolver.
+func (r *authorResolver) clientForToken(token string) *memos.Client {
+	if client, ok := r.clients[token]; ok {
+		return client
+	}
+	client := memos.NewClient(r.memosURL, token, r.memosOpts)
+	r.clients[token] = client
+	return client
+}
+
+// recordUnmapped remembers a page whose author has no mapping. The caller
+// holds r.mu.
+func (r *authorResolver) recordUnmapped(userID string, page *notion.Page) {
+	author, ok := r.unmapped[userID]
+	if !ok {
+		author = &unmappedAuthor{Name: "(no author)"}
+		if user, known := r.users[userID]; known {
+			author.Name = user.Name
+			author.Email = user.GetEmail()
+		}
+		r.unmapped[userID] = author
+	}
+	author.Pages = append(author.Pages, page.GetPageTitle())
+}
+
+// logReport lists all authors that fell back to the default account
+func (r *authorResolver) logReport() {
+	if r.mapping == nil || len(r.unmapped) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(r.unmapped))
+	for id := range r.unmapped {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	log.Printf("%d Notion author(s) are not in the user mapping; their pages were created by the default account:\n", len(ids))
+	for _, id := range ids {
+		author := r.unmapped[id]
+		label := author.Name
+		if author.Email != "" {
+			label += " <" + author.Email + ">"
+		}
+		if id != "" {
+			label += " [" + id + "]"
+		}
+		log.Printf("  %s: %d page(s): %s\n", label, len(author.Pages), strings.Join(author.Pages, ", "))
+	}
+}
@@ -0,0 +1,205 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/OneManRepo/notion2memos/internal/memos"
+	"github.com/OneManRepo/notion2memos/internal/notion"
+)
+
+// ErrPartialSuccess is returned by Migrate when pages failed with
+// ContinueOnError while the others were migrated
+var ErrPartialSuccess = errors.New("migration finished with failed pages")
+
+// errorAction is what the migration does after a page failed
+type errorAction int
+
+const (
+	// actionAbort stops the migration (e.g. a revoked token fails every page)
+	actionAbort errorAction = iota
+	// actionSkip records the page as skipped and continues with the next one
+	actionSkip
+	// actionRetry migrates the page again after a pause
+	actionRetry
+)
+
+const (
+	// maxPageAttempts bounds how often a page is migrated after the client's
+	// own request retries were exhausted
+	maxPageAttempts = 3
+	// pageRetryDelay is the pause before migrating a page again
+	pageRetryDelay = 30 * time.Second
+	// maxConsecutiveFailures stops a run with ContinueOnError once this many
+	// pages in a row failed, since the cause is then rarely the pages
+	maxConsecutiveFailures = 10
+)
+
+// Classes of the errors recorded for failed pages
+const (
+	failureNotionAuth = "notion-auth"
+	failureMemosAuth  = "memos-auth"
+	failureNotion     = "notion-api"
+	failureMemos      = "memos-api"
+	failureNetwork    = "network"
+	failureOther      = "other"
+)
+
+// classifyError decides how to continue after a page failed and returns a
+// reason suitable for the state file
+func classifyError(err error) (errorAction, string) {
+	switch {
+	case errors.Is(err, notion.ErrUnauthorized):
+		return actionAbort, "Notion token is invalid or was revoked"
+	case errors.Is(err, notion.ErrObjectNotFound):
+		return actionSkip, "page or one of its blocks no longer exists or is no longer shared with the integration"
+	case errors.Is(err, notion.ErrRestrictedResource):
+		return actionSkip, "integration lacks access to the page or one of its blocks"
+	case errors.Is(err, notion.ErrValidation):
+		return actionSkip, "Notion rejected the request: " + err.Error()
+	case errors.Is(err, notion.ErrRateLimited), errors.Is(err, notion.ErrConflict):
+		return actionRetry, "Notion kept throttling or conflicting"
+	case errors.Is(err, errAmbiguousMatch):
+		return actionSkip, err.Error()
+	}
+	return actionAbort, err.Error()
+}
+
+// failureClass returns the class of an error a page failed with
+func failureClass(err error) string {
+	var notionErr *notion.APIError
+	var memosErr *memos.APIError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, notion.ErrUnauthorized):
+		return failureNotionAuth
+	case errors.Is(err, memos.ErrUnauthorized):
+		return failureMemosAuth
+	case errors.As(err, &notionErr):
+		return failureNotion
+	case errors.As(err, &memosErr):
+		return failureMemos
+	case errors.As(err, &netErr):
+		return failureNetwork
+	}
+	return failureOther
+}
+
+// isFatal reports whether an error stops the migration even with
+// ContinueOnError, because every following page would fail the same way
+func isFatal(err error) bool {
+	switch failureClass(err) {
+	case failureNotionAuth, failureMemosAuth:
+		return true
+	}
+	return false
+}
+
+// migratePageWithPolicy migrates a page and applies the error policy: it
+// returns pageSkipped for pages that were skipped with a recorded reason and
+// an error only when the migration has to stop
+func (m *Migrator) migratePageWithPolicy(ctx context.Context, page *notion.Page) (pageOutcome, error) {
+	for attempt := 1; ; attempt++ {
+		outcome, err := m.migratePage(ctx, page)
+		if err == nil || ctx.Err() != nil {
+			return outcome, err
+		}
+
+		action, reason := classifyError(err)
+		if action == actionRetry && attempt >= maxPageAttempts {
+			action = actionSkip
+			reason = fmt.Sprintf("%s (gave up after %d attempts)", reason, attempt)
+		}
+
+		switch action {
+		case actionRetry:
+			log.Printf("\nPage '%s' failed: %v; trying again in %s (attempt %d/%d)\n",
+				page.GetPageTitle(), err, pageRetryDelay, attempt+1, maxPageAttempts)
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(pageRetryDelay):
+			}
+
+		case actionSkip:
+			log.Printf("\nSkipping page '%s': %s\n", page.GetPageTitle(), reason)
+			m.state.MarkSkipped(page.ID, page.GetPageTitle(), reason)
+			m.skippedMu.Lock()
+			m.skipped = append(m.skipped, skippedPage{title: page.GetPageTitle(), reason: reason})
+			m.skippedMu.Unlock()
+			return pageSkipped, nil
+
+		default:
+			return 0, err
+		}
+	}
+}
+
+// skippedPage is a page skipped during this run
+type skippedPage struct {
+	title  string
+	reason string
+}
+
+// logSkipped lists the pages skipped during this run
+func (m *Migrator) logSkipped() {
+	if m.empty > 0 {
+		log.Printf("Skipped %d empty pages\n", m.empty)
+	}
+	if len(m.skipped) == 0 {
+		return
+	}
+	log.Printf("Skipped %d pages:\n", len(m.skipped))
+	for _, page := range m.skipped {
+		log.Printf("  %s: %s\n", page.title, page.reason)
+	}
+}
+
+// failedPage is a page that failed during this run
+type failedPage struct {
+	id    string
+	title string
+	class string
+	err   error
+}
+
+// recordFailure records a page that failed with ContinueOnError in the state
+// and in the summary of this run
+func (m *Migrator) recordFailure(id, title string, err error) {
+	class := failureClass(err)
+	m.state.MarkFailed(id, title, class, strings.TrimSpace(err.Error()))
+
+	m.failedMu.Lock()
+	defer m.failedMu.Unlock()
+	m.failed = append(m.failed, failedPage{id: id, title: title, class: class, err: err})
+}
+
+// logFailed prints a table of the pages that failed during this run
+func (m *Migrator) logFailed() {
+	if len(m.failed) == 0 {
+		return
+	}
+	log.Printf("Failed %d pages:\n", len(m.failed))
+
+	w := tabwriter.NewWriter(log.Writer(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PAGE\tID\tCLASS\tERROR")
+	for _, page := range m.failed {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", truncate(page.title, 40), page.id, page.class, truncate(page.err.Error(), 100))
+	}
+	w.Flush()
+}
+
+// truncate shortens a string to at most n runes for a table cell
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/OneManRepo/notion2memos/internal/memos"
+	"github.com/OneManRepo/notion2memos/internal/notion"
+)
+
+func TestClassifyError(t *testing.T) {
+	notionErr := func(status int, code string) error {
+		return fmt.Errorf("failed to retrieve blocks: %w", &notion.APIError{Status: status, Code: code})
+	}
+	memosErr := func(status int) error {
+		return fmt.Errorf("failed to create memo: %w", &memos.APIError{Status: status, Body: "error"})
+	}
+	netErr := fmt.Errorf("failed to create memo: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+
+	tests := []struct {
+		name   string
+		err    error
+		action errorAction
+		class  string
+		fatal  bool
+	}{
+		{"notion unauthorized", notionErr(401, "unauthorized"), actionAbort, failureNotionAuth, true},
+		{"not found", notionErr(404, "object_not_found"), actionSkip, failureNotion, false},
+		{"restricted", notionErr(403, "restricted_resource"), actionSkip, failureNotion, false},
+		{"validation", notionErr(400, "validation_error"), actionSkip, failureNotion, false},
+		{"rate limited", notionErr(429, "rate_limited"), actionRetry, failureNotion, false},
+		{"conflict", notionErr(409, "conflict_error"), actionRetry, failureNotion, false},
+		{"notion server error", notionErr(502, "internal_server_error"), actionAbort, failureNotion, false},
+		{"ambiguous match", fmt.Errorf("%w: only 1 of 2 parts exist", errAmbiguousMatch), actionSkip, failureOther, false},
+		{"memos unauthorized", memosErr(401), actionAbort, failureMemosAuth, true},
+		{"memos forbidden", memosErr(403), actionAbort, failureMemosAuth, true},
+		{"memos server error", memosErr(500), actionAbort, failureMemos, false},
+		{"network", netErr, actionAbort, failureNetwork, false},
+		{"other", errors.New("failed to render page"), actionAbort, failureOther, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if action, _ := classifyError(tt.err); action != tt.action {
+				t.Errorf("classifyError = %d, want %d", action, tt.action)
+			}
+			if class := failureClass(tt.err); class != tt.class {
+				t.Errorf("failureClass = %q, want %q", class, tt.class)
+			}
+			if fatal := isFatal(tt.err); fatal != tt.fatal {
+				t.Errorf("isFatal = %v, want %v", fatal, tt.fatal)
+			}
+		})
+	}
+}
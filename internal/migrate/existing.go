@@ -0,0 +1,242 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OneManRepo/notion2memos/internal/memos"
+)
+
+// What to do with pages whose memos already exist on the server
+const (
+	ExistingSkip   = "skip"   // don't write the page
+	ExistingLink   = "link"   // record the existing memos in the state
+	ExistingUpdate = "update" // record the existing memos and rewrite them
+)
+
+// errAmbiguousMatch is returned for pages that can't be matched to their
+// existing memos with certainty
+var errAmbiguousMatch = errors.New("ambiguous existing memos")
+
+// memoKey is the part of a memo's fingerprint that identifies it: its title
+// and display time. The content hash tells whether it is still up to date.
+type memoKey struct {
+	title       string
+	displayTime int64 // Unix seconds
+}
+
+// memoCandidate is an existing memo with a given key
+type memoCandidate struct {
+	name string
+	hash string
+}
+
+// sourcePart identifies a part of a page's memos by its source marker
+type sourcePart struct {
+	key  string
+	part int
+}
+
+// memoIndex holds the fingerprints of the memos of one account
+type memoIndex struct {
+	byKey    map[memoKey][]memoCandidate
+	bySource map[sourcePart]memoCandidate
+	claimed  map[string]bool // memos matched to a page during this run
+}
+
+// accountIndex is the memoIndex of one account, listed on first use. Its
+// lock lets pages of other accounts go on while the memos are listed.
+type accountIndex struct {
+	mu    sync.Mutex
+	index *memoIndex // nil until listed
+}
+
+// existingMatch are the existing memos of a page, in part order
+type existingMatch struct {
+	names []string
+	// identical is set if every part has the content the page renders to
+	identical bool
+}
+
+// existingMemos finds the memos of pages migrated without this state file,
+// e.g. from another machine. Each account's memos are listed on first use.
+type existingMemos struct {
+	mode   string
+	filter string
+
+	// mu guards the accounts map, the claimed memos and the counts
+	mu       sync.Mutex
+	accounts map[*memos.Client]*accountIndex
+
+	matched   int
+	identical int
+}
+
+// newExistingMemos creates the lookup; mode is one of the Existing* constants
+func newExistingMemos(mode, filter string) *existingMemos {
+	return &existingMemos{
+		mode:     mode,
+		filter:   filter,
+		accounts: make(map[*memos.Client]*accountIndex),
+	}
+}
+
+// match returns the existing memos of a page's parts, nil if the page has
+// none. Memos carrying the page's source marker match first; otherwise
+// title and display time decide. Pages matching several memos, or only some
+// of their parts, fail with errAmbiguousMatch instead of being guessed.
+func (e *existingMemos) match(ctx context.Context, memosClient *memos.Client, src memoSource, contents []string, createdTime time.Time) (*existingMatch, error) {
+	index, err := e.index(ctx, memosClient)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	match := &existingMatch{identical: true}
+	for i, content := range contents {
+		key, hash := fingerprint(content, partTime(createdTime, i))
+		candidates := index.byKey[key]
+
+		var found *memoCandidate
+		if candidate, ok := index.bySource[sourcePart{src.key, i + 1}]; ok {
+			found = &candidate
+			candidates = []memoCandidate{candidate}
+		}
+		for _, candidate := range candidates {
+			if found == nil && candidate.hash == hash && !index.claimed[candidate.name] {
+				found = &candidate
+				break
+			}
+		}
+		if found == nil && len(candidates) == 1 {
+			found = &candidates[0]
+		}
+
+		switch {
+		case len(candidates) == 0:
+			match.names = append(match.names, "")
+			continue
+		case found == nil:
+			return nil, fmt.Errorf("%w: %d memos titled %q are shown at %s", errAmbiguousMatch,
+				len(candidates), key.title, time.Unix(key.displayTime, 0).UTC().Format(time.RFC3339))
+		case index.claimed[found.name]:
+			return nil, fmt.Errorf("%w: memo %s titled %q was already matched to another page", errAmbiguousMatch, found.name, key.title)
+		}
+		match.names = append(match.names, found.name)
+		match.identical = match.identical && found.hash == hash
+	}
+
+	missing := 0
+	for _, name := range match.names {
+		if name == "" {
+			missing++
+		}
+	}
+	switch missing {
+	case len(contents):
+		return nil, nil
+	case 0:
+	default:
+		return nil, fmt.Errorf("%w: only %d of %d parts exist", errAmbiguousMatch, len(contents)-missing, len(contents))
+	}
+
+	for _, name := range match.names {
+		index.claimed[name] = true
+	}
+	e.matched++
+	if match.identical {
+		e.identical++
+	}
+	return match, nil
+}
+
+// index returns the fingerprints of an account's memos, listing them first
+// if needed. Only pages of the same account wait for the listing; if it
+// fails, the next page tries again.
+func (e *existingMemos) index(ctx context.Context, memosClient *memos.Client) (*memoIndex, error) {
+	e.mu.Lock()
+	account, ok := e.accounts[memosClient]
+	if !ok {
+		account = &accountIndex{}
+		e.accounts[memosClient] = account
+	}
+	e.mu.Unlock()
+
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	if account.index != nil {
+		return account.index, nil
+	}
+
+	list, err := memosClient.ListMemos(ctx, memos.ListMemosOptions{Filter: e.filter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up existing memos: %w", err)
+	}
+
+	log.Printf("Found %d existing memos to match pages against\n", len(list))
+
+	account.index = newMemoIndex(list)
+	return account.index, nil
+}
+
+// newMemoIndex indexes memos by their source marker and fingerprint
+func newMemoIndex(list []memos.Memo) *memoIndex {
+	index := &memoIndex{
+		byKey:    make(map[memoKey][]memoCandidate),
+		bySource: make(map[sourcePart]memoCandidate),
+		claimed:  make(map[string]bool),
+	}
+	for _, memo := range list {
+		key, hash := fingerprint(memo.Content, memo.DisplayTime)
+		candidate := memoCandidate{name: memo.Name, hash: hash}
+		if marker, ok := parseMarker(memo.Content); ok {
+			index.bySource[sourcePart{marker.key, marker.part}] = candidate
+		}
+		if key.title == "" {
+			continue
+		}
+		index.byKey[key] = append(index.byKey[key], candidate)
+	}
+	return index
+}
+
+// log logs how many pages matched existing memos
+func (e *existingMemos) log() {
+	if e.matched == 0 {
+		return
+	}
+	action := map[string]string{
+		ExistingSkip:   "skipped",
+		ExistingLink:   "linked in the state without writing them",
+		ExistingUpdate: "updated where they differ",
+	}[e.mode]
+	log.Printf("Found existing memos for %d pages (%d unchanged): %s\n", e.matched, e.identical, action)
+}
+
+// fingerprint returns the key and content hash of a memo. The "Parts:"
+// footer is left out of the hash, since it holds server-assigned names.
+func fingerprint(content string, displayTime time.Time) (memoKey, string) {
+	content = strings.TrimSpace(content)
+
+	title, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(title, "# ") {
+		title = ""
+	}
+
+	if i := strings.LastIndex(content, "\n\nParts: [["); i >= 0 && !strings.Contains(content[i+2:], "\n") {
+		content = strings.TrimSpace(content[:i])
+	}
+	sum := sha256.Sum256([]byte(content))
+
+	key := memoKey{title: strings.TrimPrefix(title, "# "), displayTime: displayTime.Unix()}
+	return key, hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OneManRepo/notion2memos/internal/memos"
+)
+
+func TestExistingMatch(t *testing.T) {
+	created := time.Date(2024, 4, 10, 20, 0, 0, 0, time.UTC)
+	src := memoSource{key: "33333333-3333-3333-3333-333333333333", edited: "2024-04-10T21:00:00.000Z"}
+	other := memoSource{key: "55555555-5555-5555-5555-555555555555"}
+	page := []string{
+		src.withMarker("# Diary\n\nRead a book.", 0, 2),
+		src.withMarker("# Diary (2/2)\n\nWent to bed early.", 1, 2),
+	}
+	memo := func(name, content string, part int) memos.Memo {
+		return memos.Memo{Name: name, Content: content, DisplayTime: partTime(created, part)}
+	}
+
+	tests := []struct {
+		name     string
+		existing []memos.Memo
+		claimed  []string // memos matched to another page before
+		// want is nil if the page has no existing memos
+		want      []string
+		identical bool
+		ambiguous bool
+	}{
+		{"none", nil, nil, nil, false, false},
+		{"other title", []memos.Memo{memo("memos/1", "# Journal\n\nRead a book.", 0)}, nil, nil, false, false},
+		{"identical", []memos.Memo{memo("memos/1", page[0], 0), memo("memos/2", page[1], 1)}, nil,
+			[]string{"memos/1", "memos/2"}, true, false},
+		{"parts footer ignored", []memos.Memo{memo("memos/1", page[0]+"\n\nParts: [[memos/1]] [[memos/2]]", 0), memo("memos/2", page[1], 1)}, nil,
+			[]string{"memos/1", "memos/2"}, true, false},
+		{"edited without marker", []memos.Memo{memo("memos/1", "# Diary\n\nRead a novel.", 0), memo("memos/2", "# Diary (2/2)\n\nSlept.", 1)}, nil,
+			[]string{"memos/1", "memos/2"}, false, false},
+		{"renamed with marker", []memos.Memo{memo("memos/1", src.withMarker("# Old title", 0, 2), 0), memo("memos/2", src.withMarker("# Old title (2/2)", 1, 2), 1)}, nil,
+			[]string{"memos/1", "memos/2"}, false, false},
+		{"marker before fingerprint", []memos.Memo{memo("memos/1", page[0], 0), memo("memos/2", page[1], 1), memo("memos/3", other.withMarker("# Diary", 0, 1), 0)}, nil,
+			[]string{"memos/1", "memos/2"}, true, false},
+		{"several same titles", []memos.Memo{memo("memos/1", "# Diary\n\nOne.", 0), memo("memos/2", "# Diary\n\nTwo.", 0), memo("memos/3", page[1], 1)}, nil,
+			nil, false, true},
+		{"some parts", []memos.Memo{memo("memos/1", page[0], 0)}, nil, nil, false, true},
+		{"claimed by another page", []memos.Memo{memo("memos/1", page[0], 0), memo("memos/2", page[1], 1)}, []string{"memos/2"},
+			nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := memos.NewClient("http://memos.invalid", "token", memos.ClientOptions{})
+			index := newMemoIndex(tt.existing)
+			for _, name := range tt.claimed {
+				index.claimed[name] = true
+			}
+			e := newExistingMemos(ExistingLink, "")
+			e.accounts[client] = &accountIndex{index: index}
+
+			match, err := e.match(context.Background(), client, src, page, created)
+			if tt.ambiguous {
+				if !errors.Is(err, errAmbiguousMatch) {
+					t.Fatalf("got %v, want an ambiguous match", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if match != nil {
+					t.Fatalf("got %v, want no match", match.names)
+				}
+				return
+			}
+			if match == nil {
+				t.Fatalf("got no match, want %v", tt.want)
+			}
+			if !reflect.DeepEqual(match.names, tt.want) || match.identical != tt.identical {
+				t.Errorf("got %v (identical %v), want %v (identical %v)", match.names, match.identical, tt.want, tt.identical)
+			}
+			for _, name := range tt.want {
+				if !index.claimed[name] {
+					t.Errorf("%s isn't claimed", name)
+				}
+			}
+		})
+	}
+}
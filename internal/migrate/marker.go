@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sourceMarkerReserve is the room kept free in every memo for its source
+// marker
+const sourceMarkerReserve = 160
+
+// contentLimit is the longest Markdown a memo can hold besides its source
+// marker
+func (m *Migrator) contentLimit() int {
+	return m.lengthLimit - sourceMarkerReserve
+}
+
+// markerPattern matches the source marker, e.g.
+// "<!-- notion2memos page=… edited=2024-04-10T20:00:00.000Z part=1/2 -->"
+var markerPattern = regexp.MustCompile(`<!-- notion2memos ([^>]*) -->`)
+
+// memoSource identifies the Notion object a memo is written from
+type memoSource struct {
+	key    string // state key: the page ID or snapshotStateKey of a database
+	edited string // last_edited_time of the object
+}
+
+// marker returns the hidden comment identifying part of parts, so that the
+// state can be rebuilt from the memos on the server
+func (s memoSource) marker(part, parts int) string {
+	fields := "page=" + s.key
+	if id, ok := strings.CutPrefix(s.key, snapshotStateKey("")); ok {
+		fields = "database=" + id
+	}
+	if s.edited != "" {
+		fields += " edited=" + s.edited
+	}
+	return fmt.Sprintf("<!-- notion2memos %s part=%d/%d -->", fields, part, parts)
+}
+
+// memoID derives the ID of a part's memo: stable across runs and machines,
+// and within the 32 characters Memos allows
+func (s memoSource) memoID(part int) string {
+	sum := sha256.Sum256([]byte(normalizeID(s.key) + "/" + strconv.Itoa(part)))
+	return "n2m-" + hex.EncodeToString(sum[:12])
+}
+
+// withMarker appends the source marker of part i of a page's memos
+func (s memoSource) withMarker(content string, i, parts int) string {
+	return strings.TrimRight(content, " \n") + "\n\n" + s.marker(i+1, parts)
+}
+
+// parsedMarker is the source marker read back from a memo
+type parsedMarker struct {
+	memoSource
+	part  int // 1-based
+	parts int
+}
+
+// parseMarker reads the source marker of a memo's content
+func parseMarker(content string) (parsedMarker, bool) {
+	matches := markerPattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return parsedMarker{}, false
+	}
+
+	var marker parsedMarker
+	for _, field := range strings.Fields(matches[len(matches)-1][1]) {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "page":
+			marker.key = value
+		case "database":
+			marker.key = snapshotStateKey(value)
+		case "edited":
+			marker.edited = value
+		case "part":
+			part, parts, _ := strings.Cut(value, "/")
+			marker.part, _ = strconv.Atoi(part)
+			marker.parts, _ = strconv.Atoi(parts)
+		}
+	}
+
+	if marker.key == "" || marker.part < 1 || marker.part > marker.parts {
+		return parsedMarker{}, false
+	}
+	return marker, true
+}
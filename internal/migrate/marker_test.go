@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkerRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         memoSource
+		part, parts int
+	}{
+		{"page", memoSource{key: "22222222-2222-2222-2222-222222222222", edited: "2024-03-06T08:15:00.000Z"}, 1, 1},
+		{"later part", memoSource{key: "22222222-2222-2222-2222-222222222222", edited: "2024-03-06T08:15:00.000Z"}, 3, 4},
+		{"without edit time", memoSource{key: "22222222-2222-2222-2222-222222222222"}, 1, 2},
+		{"database snapshot", memoSource{key: snapshotStateKey("44444444-4444-4444-4444-444444444444"), edited: "2024-04-10T20:00:00.000Z"}, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.src.withMarker("# Title\n\nSome text\n\n", tt.part-1, tt.parts)
+			if !strings.HasPrefix(content, "# Title\n\nSome text\n\n<!-- ") {
+				t.Errorf("marker isn't appended after a blank line:\n%s", content)
+			}
+			if n := len(tt.src.marker(tt.part, tt.parts)); n > sourceMarkerReserve {
+				t.Errorf("marker is %d bytes, more than the %d reserved", n, sourceMarkerReserve)
+			}
+
+			marker, ok := parseMarker(content)
+			if !ok {
+				t.Fatalf("marker not found in %q", content)
+			}
+			if marker.memoSource != tt.src || marker.part != tt.part || marker.parts != tt.parts {
+				t.Errorf("got %+v part %d/%d, want %+v part %d/%d", marker.memoSource, marker.part, marker.parts, tt.src, tt.part, tt.parts)
+			}
+		})
+	}
+}
+
+func TestParseMarker(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    parsedMarker
+		ok      bool
+	}{
+		{"no marker", "# Title\n\nText", parsedMarker{}, false},
+		{"other comment", "Text <!-- note -->", parsedMarker{}, false},
+		{"last marker wins", "Quoted <!-- notion2memos page=a part=1/1 -->\n\n<!-- notion2memos page=b part=2/3 -->",
+			parsedMarker{memoSource: memoSource{key: "b"}, part: 2, parts: 3}, true},
+		{"unknown fields", "<!-- notion2memos page=a origin=x part=1/1 -->",
+			parsedMarker{memoSource: memoSource{key: "a"}, part: 1, parts: 1}, true},
+		{"no source", "<!-- notion2memos part=1/1 -->", parsedMarker{}, false},
+		{"no part", "<!-- notion2memos page=a -->", parsedMarker{}, false},
+		{"part beyond parts", "<!-- notion2memos page=a part=3/2 -->", parsedMarker{}, false},
+		{"malformed part", "<!-- notion2memos page=a part=one/two -->", parsedMarker{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseMarker(tt.content)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("got %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OneManRepo/notion2memos/internal/config"
+	"github.com/OneManRepo/notion2memos/internal/fakeserver"
+)
+
+// testEnv runs migrations against a fake server serving a copy of the
+// basic fixtures, with the state kept in a temporary home directory
+type testEnv struct {
+	t        *testing.T
+	fixtures string
+	server   *fakeserver.Server
+	url      string
+}
+
+// newTestEnv starts a fake server for a copy of testdata/basic
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	fixtures := t.TempDir()
+	if err := os.CopyFS(fixtures, os.DirFS("../fakeserver/testdata/basic")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", t.TempDir())
+	// Dry runs write to ./dry-run-output
+	t.Chdir(t.TempDir())
+
+	server, err := fakeserver.New(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	return &testEnv{t: t, fixtures: fixtures, server: server, url: ts.URL}
+}
+
+// migrator creates a migrator for the fake server; extraConfig is appended
+// to the config file
+func (e *testEnv) migrator(dryRun bool, extraConfig string) *Migrator {
+	e.t.Helper()
+	path := filepath.Join(e.t.TempDir(), "config.yaml")
+	cfg := fmt.Sprintf("notion_token: notion-token\nnotion_api_url: %s/v1\nmemos_url: %s\nmemos_token: memos-token\n%s",
+		e.url, e.url, extraConfig)
+	if err := os.WriteFile(path, []byte(cfg), 0600); err != nil {
+		e.t.Fatal(err)
+	}
+
+	loaded, err := config.Load(path)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	m, err := NewMigrator(loaded, dryRun, false)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return m
+}
+
+// writeFixture writes a fixture file and makes the server serve it
+func (e *testEnv) writeFixture(name string, v any) {
+	e.t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(e.fixtures, name), data, 0644); err != nil {
+		e.t.Fatal(err)
+	}
+	if err := e.server.Reload(e.fixtures); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// editFixture replaces old with new in a fixture file and makes the server
+// serve it
+func (e *testEnv) editFixture(name, old, new string) {
+	e.t.Helper()
+	path := filepath.Join(e.fixtures, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if !strings.Contains(string(data), old) {
+		e.t.Fatalf("%s doesn't contain %q", name, old)
+	}
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(string(data), old, new)), 0644); err != nil {
+		e.t.Fatal(err)
+	}
+	if err := e.server.Reload(e.fixtures); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// memosOf returns the memos whose source marker names the Notion page, in
+// the order they were created
+func (e *testEnv) memosOf(pageID string) []fakeserver.Memo {
+	var found []fakeserver.Memo
+	for _, memo := range e.server.Memos() {
+		if marker, ok := parseMarker(memo.Content); ok && marker.key == pageID {
+			found = append(found, memo)
+		}
+	}
+	return found
+}
+
+// addLongPage adds a workspace page long enough to be split into several
+// memos and returns its ID
+func (e *testEnv) addLongPage(created string) string {
+	const id = "77777777-7777-7777-7777-777777777777"
+	e.writeFixture("pages/long.json", map[string]any{
+		"object":           "page",
+		"id":               id,
+		"created_time":     created,
+		"last_edited_time": created,
+		"parent":           map[string]any{"type": "workspace", "workspace": true},
+		"properties": map[string]any{
+			"title": map[string]any{"id": "title", "type": "title", "title": []map[string]any{
+				{"type": "text", "plain_text": "Long page"},
+			}},
+		},
+	})
+
+	var blocks []map[string]any
+	for i := range 60 {
+		blocks = append(blocks, map[string]any{
+			"object":       "block",
+			"id":           fmt.Sprintf("d0000000-0000-0000-0000-%012d", i),
+			"type":         "paragraph",
+			"has_children": false,
+			"paragraph": map[string]any{"rich_text": []map[string]any{
+				{"type": "text", "plain_text": fmt.Sprintf("Paragraph %d: %s", i, strings.Repeat("Äpfel und Öl über Straße. ", 8))},
+			}},
+		})
+	}
+	e.writeFixture("blocks/"+id+".json", blocks)
+	return id
+}
+
+func TestMigrate(t *testing.T) {
+	env := newTestEnv(t)
+	longID := env.addLongPage("2024-05-01T09:30:00.000Z")
+
+	if err := env.migrator(false, "").Migrate(context.Background(), MigrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		pageID   string
+		created  string
+		parts    int
+		contains string
+	}{
+		{"database row", "33333333-3333-3333-3333-333333333333", "2024-04-10T20:00:00Z", 1, "Read a book and went to bed early."},
+		{"nested list", "22222222-2222-2222-2222-222222222222", "2024-03-02T14:30:00Z", 1, "  - Map Notion blocks to Markdown"},
+		{"child page", "66666666-6666-6666-6666-666666666666", "2024-03-03T11:00:00Z", 1, "#Projects #Roadmap"},
+		{"split page", longID, "2024-05-01T09:30:00Z", 2, "Paragraph 59"},
+		{"empty page", "11111111-1111-1111-1111-111111111111", "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memos := env.memosOf(tt.pageID)
+			if len(memos) != tt.parts {
+				t.Fatalf("got %d memos, want %d", len(memos), tt.parts)
+			}
+			if tt.parts == 0 {
+				return
+			}
+
+			created, err := time.Parse(time.RFC3339, tt.created)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var all strings.Builder
+			for i, memo := range memos {
+				if len(memo.Content) > fakeserver.DefaultContentLengthLimit {
+					t.Errorf("part %d is %d bytes", i+1, len(memo.Content))
+				}
+				marker, _ := parseMarker(memo.Content)
+				if marker.part != i+1 || marker.parts != tt.parts {
+					t.Errorf("part %d has marker part %d/%d", i+1, marker.part, marker.parts)
+				}
+				displayTime, err := time.Parse(time.RFC3339, memo.DisplayTime)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := partTime(created, i); !displayTime.Equal(want) {
+					t.Errorf("part %d is shown at %s, want %s", i+1, displayTime, want)
+				}
+				all.WriteString(memo.Content)
+			}
+			if !strings.Contains(all.String(), tt.contains) {
+				t.Errorf("memos don't contain %q:\n%s", tt.contains, all.String())
+			}
+			if tt.parts > 1 && !strings.HasPrefix(memos[1].Content, fmt.Sprintf("# Long page (2/%d)", tt.parts)) {
+				t.Errorf("second part starts with %q", memos[1].Content[:min(len(memos[1].Content), 30)])
+			}
+		})
+	}
+}
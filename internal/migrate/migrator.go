@@ -1,41 +1,125 @@
 package migrate
 
 import (
+	"context"
 	"fmt"
+	"iter"
 	"log"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/OneManRepo/notion2memos/internal/cache"
 	"github.com/OneManRepo/notion2memos/internal/config"
 	"github.com/OneManRepo/notion2memos/internal/memos"
 	"github.com/OneManRepo/notion2memos/internal/notion"
 	"github.com/schollz/progressbar/v3"
 )
 
+// Bounds of the parent caches. Parents repeat across many pages, so a few
+// thousand entries keep the hit rate high without unbounded growth.
+const (
+	pageCacheSize     = 2000
+	databaseCacheSize = 500
+)
+
 // Migrator coordinates the migration from Notion to Memos
 type Migrator struct {
-	notionClient  *notion.Client
-	memosClient   *memos.Client
-	state         *config.State
-	dryRun        bool
-	pageCache     map[string]*notion.Page
-	databaseCache map[string]*notion.Database
+	notionClient    *notion.Client
+	memosClient     *memos.Client
+	authors         *authorResolver
+	state           *config.State
+	dryRun          bool
+	pageCache       *lruCache[string, *notion.Page]
+	databaseCache   *lruCache[string, *notion.Database]
+	dataSourceCache *lruCache[string, *notion.DataSource]
+	blockCache      *lruCache[string, *notion.Block]
+	diskCache       *cache.Cache // nil when caching is disabled
+
+	prefetchMu sync.Mutex
+	prefetched map[string]*blockFetch // block fetches started ahead, by page ID
+
+	skippedMu sync.Mutex
+	skipped   []skippedPage // pages skipped during this run
+	empty     int           // pages without content during this run
+	failedMu  sync.Mutex
+	failed    []failedPage // pages that failed during this run
+
+	splitMemos  config.SplitMemosConfig
+	lengthLimit int // longest memo content in bytes, from the config or checkServer
+	tags        config.TagsConfig
+	tagRules    tagRules
+	update      bool       // rewrite the recorded memos of pages instead of creating new ones
+	written     memoCounts // memo writes during this run
+	memoIDs     bool       // derive memo IDs from the source and part
+
+	existing *existingMemos // nil unless existing memos are looked up
+	synced   *syncCounts    // nil unless syncing
+
+	snapshotConfigs []config.DatabaseSnapshot
+	snapshots       map[string]*snapshotDatabase // keyed by normalized database ID
 }
 
-// NewMigrator creates a new Migrator
-func NewMigrator(cfg *config.Config, dryRun bool) (*Migrator, error) {
+// NewMigrator creates a new Migrator. With useCache, Notion responses are
+// cached on disk across runs.
+func NewMigrator(cfg *config.Config, dryRun, useCache bool) (*Migrator, error) {
 	state, err := config.LoadState()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load state: %w", err)
 	}
 
+	var diskCache *cache.Cache
+	if useCache {
+		cacheDir, err := config.GetCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		diskCache = cache.Open(cacheDir)
+	}
+
+	tagRules, err := newTagRules(cfg.Tags.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping *config.UserMapping
+	if cfg.UserMappingFile != "" {
+		mapping, err = config.LoadUserMapping(cfg.UserMappingFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	notionClient := notion.NewClient(cfg.NotionToken, notion.ClientOptions{
+		BaseURL: cfg.NotionAPIURL,
+		Retry: notion.RetryOptions{
+			MaxRetries:     cfg.NotionRetry.MaxRetries,
+			InitialBackoff: cfg.NotionRetry.InitialBackoff,
+			MaxBackoff:     cfg.NotionRetry.MaxBackoff,
+		},
+	})
+	memosOpts := memos.ClientOptions{}
+	memosClient := memos.NewClient(cfg.MemosURL, cfg.MemosToken, memosOpts)
+
 	return &Migrator{
-		notionClient:  notion.NewClient(cfg.NotionToken),
-		memosClient:   memos.NewClient(cfg.MemosURL, cfg.MemosToken),
+		notionClient:  notionClient,
+		memosClient:   memosClient,
+		authors:       newAuthorResolver(notionClient, cfg.MemosURL, memosOpts, mapping, memosClient),
 		state:         state,
 		dryRun:        dryRun,
-		pageCache:     make(map[string]*notion.Page),
-		databaseCache: make(map[string]*notion.Database),
+		pageCache:     newLRUCache[string, *notion.Page](pageCacheSize),
+		databaseCache: newLRUCache[string, *notion.Database](databaseCacheSize),
+
+		dataSourceCache: newLRUCache[string, *notion.DataSource](databaseCacheSize),
+		blockCache:      newLRUCache[string, *notion.Block](pageCacheSize),
+		diskCache:       diskCache,
+		prefetched:      make(map[string]*blockFetch),
+
+		splitMemos:      cfg.SplitMemos,
+		lengthLimit:     cfg.MemoLengthLimit,
+		tags:            cfg.Tags,
+		tagRules:        tagRules,
+		memoIDs:         cfg.DeterministicMemoIDs,
+		snapshotConfigs: cfg.DatabaseSnapshots,
 	}, nil
 }
 
@@ -43,84 +127,183 @@ func NewMigrator(cfg *config.Config, dryRun bool) (*Migrator, error) {
 type MigrateOptions struct {
 	Resume       bool
 	FilterTitles []string
+	// Update rewrites the memos recorded for already migrated pages instead
+	// of creating new ones
+	Update bool
+	// Existing looks up the memos of pages that have none recorded on the
+	// server and skips, links or updates them (one of the Existing*
+	// constants); empty disables the lookup
+	Existing string
+	// ExistingFilter is the server-side filter for listing existing memos
+	ExistingFilter string
+	// Workers is the number of pages migrated concurrently (at least 1)
+	Workers int
+	// ContinueOnError records failed pages in the state and goes on with the
+	// next ones instead of stopping; Migrate then returns ErrPartialSuccess
+	ContinueOnError bool
+	// RetryFailed migrates only the pages recorded as failed
+	RetryFailed bool
 }
 
 // Migrate performs the migration from Notion to Memos
-func (m *Migrator) Migrate(opts MigrateOptions) error {
+func (m *Migrator) Migrate(ctx context.Context, opts MigrateOptions) error {
 	log.Println("Starting migration from Notion to Memos...")
 
 	if m.dryRun {
 		log.Println("DRY RUN MODE: Memos will be saved to ./dry-run-output/ instead of being created")
 	}
+	m.update = opts.Update
 
-	// Search for all pages
-	log.Println("Searching for pages in Notion...")
-	pages, err := m.notionClient.SearchPages("")
-	if err != nil {
-		return fmt.Errorf("failed to search pages: %w", err)
+	if err := m.checkServer(ctx); err != nil {
+		return err
+	}
+	if opts.Existing != "" {
+		if m.dryRun {
+			log.Println("Existing memos are not looked up in dry-run mode")
+		} else {
+			m.existing = newExistingMemos(opts.Existing, opts.ExistingFilter)
+		}
+	}
+	if pending := m.state.PendingMemoCount(); pending > 0 {
+		log.Printf("%d memos of a previous run still need their display time; it is set when their pages are migrated again\n", pending)
+	}
+	if opts.RetryFailed {
+		failed := m.state.FailedPageCount()
+		if failed == 0 {
+			log.Println("No failed pages to retry")
+			return nil
+		}
+		log.Printf("Retrying %d failed pages\n", failed)
 	}
 
-	log.Printf("Found %d pages\n", len(pages))
+	// Resolve databases rendered as table snapshots
+	if err := m.resolveSnapshots(ctx); err != nil {
+		return err
+	}
+	if err := m.migrateSnapshots(ctx, opts, time.Time{}); err != nil {
+		return err
+	}
 
-	// Filter pages if titles are specified
-	if len(opts.FilterTitles) > 0 {
-		pages = m.filterPagesByTitle(pages, opts.FilterTitles)
-		log.Printf("Filtered to %d pages matching specified titles\n", len(pages))
+	// Stream pages from Notion: the first page is migrated as soon as the
+	// first search batch arrives, and the blocks of the next pages are
+	// fetched while earlier ones are being written
+	log.Println("Searching for pages in Notion...")
+	filter := newPageFilter(opts)
+	if opts.Workers > 1 {
+		log.Printf("Migrating %d pages at a time\n", opts.Workers)
 	}
+	bar := progressbar.Default(-1, "Migrating pages")
 
-	// Filter out already processed pages if resuming
-	if opts.Resume {
-		originalCount := len(pages)
-		pages = m.filterProcessedPages(pages)
-		skipped := originalCount - len(pages)
-		if skipped > 0 {
-			log.Printf("Skipping %d already processed pages (resume mode)\n", skipped)
-		}
+	search := func(ctx context.Context) iter.Seq2[notion.Page, error] {
+		return m.notionClient.SearchPagesIter(ctx, "")
+	}
+	successCount, err := m.migratePages(ctx, search, filter, opts, bar)
+	if ctx.Err() != nil {
+		bar.Close()
+		return m.interrupted(ctx, successCount)
+	}
+	if err != nil {
+		bar.Close()
+		m.logFailed()
+		return err
 	}
 
-	if len(pages) == 0 {
+	filter.logSummary()
+	if filter.found == 0 || filter.found == filter.skipped() {
+		bar.Close()
 		log.Println("No pages to migrate")
 		return nil
 	}
 
-	// Create progress bar
-	bar := progressbar.Default(int64(len(pages)), "Migrating pages")
-
-	// Process each page
-	successCount := 0
-	for _, page := range pages {
-		if err := m.migratePage(&page); err != nil {
-			bar.Close()
-			return fmt.Errorf("failed to migrate page %s (%s): %w", page.GetPageTitle(), page.ID, err)
-		}
-
-		// Mark as processed and save state
-		m.state.MarkProcessed(page.ID)
-		if err := m.state.SaveState(); err != nil {
-			bar.Close()
-			return fmt.Errorf("failed to save state: %w", err)
-		}
+	bar.Finish()
+	if len(m.failed) > 0 {
+		log.Printf("\nMigration completed with errors. Migrated %d pages, %d failed\n", successCount, len(m.failed))
+	} else {
+		log.Printf("\nMigration completed successfully! Migrated %d pages\n", successCount)
+	}
+	if retries := m.notionClient.Retries(); retries > 0 {
+		log.Printf("Notion API requests were retried %d times\n", retries)
+	}
+	m.logCacheStats()
+	m.written.log(m.update)
+	if m.existing != nil {
+		m.existing.log()
+	}
+	m.logSkipped()
+	m.authors.logReport()
 
-		successCount++
-		bar.Add(1)
+	if m.dryRun {
+		log.Println("Check ./dry-run-output/ for the generated markdown files")
 	}
 
-	bar.Finish()
-	log.Printf("\nMigration completed successfully! Migrated %d pages\n", successCount)
+	if len(m.failed) > 0 {
+		m.logFailed()
+		log.Println("Run 'notion2memos migrate --retry-failed' to migrate the failed pages again.")
+		return fmt.Errorf("%w: %d pages failed", ErrPartialSuccess, len(m.failed))
+	}
+	return nil
+}
 
+// checkServer fails before Notion is touched if the Memos server can't be
+// used
+func (m *Migrator) checkServer(ctx context.Context) error {
 	if m.dryRun {
-		log.Println("Check ./dry-run-output/ for the generated markdown files")
+		return m.resolveLengthLimit(ctx)
+	}
+	info, err := m.memosClient.ServerInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot use Memos server: %w", err)
 	}
+	log.Printf("Connected to Memos %s (API: %s)\n", info.Version, info.API)
+	if m.memoIDs && !info.MemoIDs {
+		return fmt.Errorf("deterministic_memo_ids requires Memos 0.25 or later, the server runs %s", info.Version)
+	}
+	return m.resolveLengthLimit(ctx)
+}
 
+// resolveLengthLimit settles the length limit memos are split at: the
+// configured one, else the server's. Dry runs don't ask the server and use
+// the Memos default.
+func (m *Migrator) resolveLengthLimit(ctx context.Context) error {
+	source := "memo_length_limit"
+	switch {
+	case m.lengthLimit > 0:
+	case m.dryRun:
+		m.lengthLimit, source = memos.DefaultContentLengthLimit, "Memos default"
+	default:
+		limit, err := m.memosClient.ContentLengthLimit(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot use Memos server: %w", err)
+		}
+		if limit < config.MinMemoLengthLimit {
+			return fmt.Errorf("the Memos server limits memos to %d bytes, at least %d are needed to split pages", limit, config.MinMemoLengthLimit)
+		}
+		m.lengthLimit, source = limit, "server setting"
+	}
+	log.Printf("Memos longer than %d bytes are split (%s)\n", m.lengthLimit, source)
 	return nil
 }
 
-// migratePage migrates a single page from Notion to Memos
-func (m *Migrator) migratePage(page *notion.Page) error {
-	// Retrieve page blocks
-	blocks, err := m.notionClient.RetrieveBlocks(page.ID)
+// interrupted persists the state after the migration was cancelled and
+// prints how to resume it
+func (m *Migrator) interrupted(ctx context.Context, migrated int) error {
+	if err := m.state.SaveState(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	log.Printf("\nMigration interrupted after migrating %d pages\n", migrated)
+	log.Println("State has been saved. Run 'notion2memos migrate --resume' to continue where it stopped.")
+
+	return fmt.Errorf("migration interrupted: %w", ctx.Err())
+}
+
+// migratePage migrates a single page from Notion to Memos. Pages without
+// content are recorded but get no memo and report pageEmpty.
+func (m *Migrator) migratePage(ctx context.Context, page *notion.Page) (pageOutcome, error) {
+	// Retrieve page blocks (unchanged pages come from the disk cache)
+	blocks, err := m.getBlocksCached(ctx, page)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve blocks: %w", err)
+		return 0, fmt.Errorf("failed to retrieve blocks: %w", err)
 	}
 
 	// Get page title
@@ -128,35 +311,41 @@ func (m *Migrator) migratePage(page *notion.Page) error {
 
 	// Skip pages with no content blocks
 	if len(blocks) == 0 {
-		log.Printf("Skipping empty page (no blocks): %s\n", pageTitle)
-		return nil
+		m.recordPage(page, "")
+		return pageEmpty, nil
 	}
 
 	// Get parent tags (using cache)
-	tags, err := m.getParentTagsCached(page)
+	tags, err := m.getParentTagsCached(ctx, page.Parent)
 	if err != nil {
 		// Log warning but continue - tags are not critical
 		log.Printf("Warning: failed to retrieve parent tags for page %s: %v\n", page.GetPageTitle(), err)
 	}
 
-	// Replace "Tagebuch" tag with "tagebuch" (lowercase)
-	for i, tag := range tags {
-		if tag == "Tagebuch" {
-			tags[i] = "tagebuch"
-			break
-		}
+	// Render inline databases configured for snapshots
+	childDatabases, err := m.renderInlineSnapshots(ctx, blocks)
+	if err != nil {
+		return 0, fmt.Errorf("failed to render inline databases: %w", err)
 	}
 
 	// Convert blocks to Markdown with title and tags
-	markdown, err := notion.BlocksToMarkdown(blocks, page.CreatedTime, pageTitle, tags)
+	opts := m.tagOptions()
+	opts.ChildDatabases = childDatabases
+	markdown, err := notion.BlocksToMarkdown(blocks, page.CreatedTime, pageTitle, tags, opts)
 	if err != nil {
-		return fmt.Errorf("failed to convert to markdown: %w", err)
+		return 0, fmt.Errorf("failed to convert to markdown: %w", err)
 	}
 
 	// If content is empty after conversion, skip
 	if markdown == "" {
-		log.Printf("Skipping empty page: %s\n", pageTitle)
-		return nil
+		m.recordPage(page, "")
+		return pageEmpty, nil
+	}
+
+	// A sync leaves pages alone whose edits don't change their Markdown
+	hash := contentHash(markdown)
+	if m.synced != nil && m.keepUnchanged(page, hash) {
+		return pageMigrated, nil
 	}
 
 	// Parse created time from Notion (RFC3339 format)
@@ -166,221 +355,306 @@ func (m *Migrator) migratePage(page *notion.Page) error {
 		createdTime = time.Now()
 	}
 
-	// Check if content exceeds Memos API limit and split if necessary
-	const memosMaxLength = 8192
-	if len(markdown) > memosMaxLength {
-		log.Printf("Page '%s' exceeds character limit (%d chars). Splitting into multiple memos...\n", pageTitle, len(markdown))
-		if err := m.createSplitMemos(markdown, pageTitle, createdTime); err != nil {
-			return fmt.Errorf("failed to create split memos: %w", err)
-		}
-	} else {
-		// Create single memo in Memos
-		if err := m.memosClient.CreateMemo(markdown, createdTime, m.dryRun); err != nil {
-			return fmt.Errorf("failed to create memo: %w", err)
-		}
-	}
-
-	return nil
-}
-
-// createSplitMemos splits a long memo into multiple parts and creates them
-func (m *Migrator) createSplitMemos(content, pageTitle string, createdTime time.Time) error {
-	const memosMaxLength = 8192
-	const splitMarker = "\n\n..."
-	const continuationMarker = "...\n\n"
-
-	// Calculate safe chunk size (leaving room for markers and title modification)
-	const safeChunkSize = memosMaxLength - 200 // Reserve space for title, tags, and markers
-
-	var parts []string
-	remaining := content
-
-	// Split content into chunks
-	for len(remaining) > safeChunkSize {
-		// Find a good breaking point (end of line) before the chunk size
-		breakPoint := safeChunkSize
-		for breakPoint > 0 && remaining[breakPoint] != '\n' {
-			breakPoint--
-		}
+	// Pick the Memos account of the page's author
+	memosClient := m.authors.clientFor(ctx, page)
 
-		// If no newline found, just break at chunk size
-		if breakPoint == 0 {
-			breakPoint = safeChunkSize
+	// Abandon the page if interrupted before anything was written. Once the
+	// first memo is created the page is finished regardless, so that split
+	// memos are never left half-created.
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	// Check if content exceeds the server's limit and split if necessary
+	contents := []string{markdown}
+	if length := memos.ContentLength(markdown); length > m.contentLimit() {
+		log.Printf("Page '%s' exceeds the memo length limit (%d bytes). Splitting into multiple memos...\n", pageTitle, length)
+		titleTags, endTags := "", ""
+		if !opts.NoTitleTags {
+			titleTags = notion.TagLine(tags)
 		}
-
-		parts = append(parts, remaining[:breakPoint])
-		remaining = remaining[breakPoint:]
-
-		// Trim leading whitespace from remaining content
-		for len(remaining) > 0 && (remaining[0] == '\n' || remaining[0] == ' ') {
-			remaining = remaining[1:]
+		if opts.EndTags {
+			endTags = notion.TagLine(tags)
 		}
+		contents = m.splitMemo(markdown, pageTitle, titleTags, endTags)
 	}
 
-	// Add the final part
-	if len(remaining) > 0 {
-		parts = append(parts, remaining)
+	existed := len(m.state.GetMemos(page.ID)) > 0
+	src := memoSource{key: page.ID, edited: page.LastEditedTime}
+	if err := m.writeMemos(ctx, memosClient, src, pageTitle, contents, createdTime); err != nil {
+		return 0, err
 	}
 
-	log.Printf("Split page '%s' into %d parts\n", pageTitle, len(parts))
+	// Remember the version written, so that a sync can tell later edits
+	m.recordPage(page, hash)
+	if m.synced != nil {
+		m.synced.count(existed)
+	}
 
-	// Create each part as a separate memo
-	for i, part := range parts {
-		partNumber := i + 1
-		partTitle := fmt.Sprintf("%s (%d/%d)", pageTitle, partNumber, len(parts))
+	return pageMigrated, nil
+}
 
-		// Replace the original title with the numbered title
-		lines := strings.Split(part, "\n")
-		var contentWithoutTitle string
-		startIndex := 0
+// recordPage records the version of a page that was migrated. Dry runs
+// record nothing: a later real sync would take the page for up to date and
+// leave its memos as they are.
+func (m *Migrator) recordPage(page *notion.Page, hash string) {
+	if m.dryRun {
+		return
+	}
+	m.state.RecordPage(page.ID, page.GetPageTitle(), page.LastEditedTime, hash)
+}
 
-		// Find and remove the original title if present
-		if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
-			startIndex = 1
-		}
-		contentWithoutTitle = strings.Join(lines[startIndex:], "\n")
-
-		// Build the memo content with markers
-		var memoContent string
-		if i == 0 {
-			// First part: title + content + "..."
-			memoContent = "# " + partTitle + "\n\n" + contentWithoutTitle + splitMarker
-		} else if i == len(parts)-1 {
-			// Last part: title + "..." + content
-			memoContent = "# " + partTitle + "\n\n" + continuationMarker + contentWithoutTitle
-		} else {
-			// Middle parts: title + "..." + content + "..."
-			memoContent = "# " + partTitle + "\n\n" + continuationMarker + contentWithoutTitle + splitMarker
-		}
+// tagOptions returns the Markdown options placing the tags as configured
+func (m *Migrator) tagOptions() notion.MarkdownOptions {
+	return notion.MarkdownOptions{
+		NoTitleTags: m.tags.Placement == config.TagsAtEnd,
+		EndTags:     m.tags.Placement != config.TagsAfterTitle,
+	}
+}
 
-		// Offset creation time by a few seconds for each part
-		partCreatedTime := createdTime.Add(time.Duration(i*5) * time.Second)
+// pageFilter decides which of the streamed pages are migrated and counts
+// the skipped ones
+type pageFilter struct {
+	titles      map[string]bool
+	resume      bool
+	retryFailed bool
+	changedOnly bool // skip pages not edited since their record
+
+	found            int
+	skippedSnapshot  int
+	skippedTitle     int
+	skippedProcessed int
+	skippedNotFailed int
+	skippedUnchanged int
+}
 
-		// Create the memo
-		if err := m.memosClient.CreateMemo(memoContent, partCreatedTime, m.dryRun); err != nil {
-			return fmt.Errorf("failed to create memo part %d: %w", partNumber, err)
+// newPageFilter creates a filter for the given options
+func newPageFilter(opts MigrateOptions) *pageFilter {
+	f := &pageFilter{resume: opts.Resume, retryFailed: opts.RetryFailed}
+	if len(opts.FilterTitles) > 0 {
+		f.titles = make(map[string]bool)
+		for _, title := range opts.FilterTitles {
+			f.titles[title] = true
 		}
-
-		log.Printf("Created memo part %d/%d for page '%s'\n", partNumber, len(parts), pageTitle)
 	}
+	return f
+}
 
-	return nil
+// skipped returns the number of pages filtered out
+func (f *pageFilter) skipped() int {
+	return f.skippedSnapshot + f.skippedTitle + f.skippedProcessed + f.skippedNotFailed + f.skippedUnchanged
 }
 
-// filterPagesByTitle filters pages to only include those with matching titles
-func (m *Migrator) filterPagesByTitle(pages []notion.Page, titles []string) []notion.Page {
-	if len(titles) == 0 {
-		return pages
+// logSummary logs how many pages were found and why pages were skipped
+func (f *pageFilter) logSummary() {
+	log.Printf("\nFound %d pages\n", f.found)
+	if f.skippedSnapshot > 0 {
+		log.Printf("Skipped %d rows of snapshot databases\n", f.skippedSnapshot)
 	}
-
-	titleSet := make(map[string]bool)
-	for _, title := range titles {
-		titleSet[title] = true
+	if f.titles != nil {
+		log.Printf("Filtered to %d pages matching specified titles\n", f.found-f.skippedSnapshot-f.skippedTitle)
 	}
-
-	var filtered []notion.Page
-	for _, page := range pages {
-		if titleSet[page.GetPageTitle()] {
-			filtered = append(filtered, page)
-		}
+	if f.skippedProcessed > 0 {
+		log.Printf("Skipped %d already processed pages (resume mode)\n", f.skippedProcessed)
+	}
+	if f.skippedNotFailed > 0 {
+		log.Printf("Skipped %d pages that didn't fail (retry mode)\n", f.skippedNotFailed)
+	}
+	if f.skippedUnchanged > 0 {
+		log.Printf("Skipped %d pages not edited since they were migrated\n", f.skippedUnchanged)
 	}
-
-	return filtered
 }
 
-// filterProcessedPages removes already processed pages
-func (m *Migrator) filterProcessedPages(pages []notion.Page) []notion.Page {
-	var filtered []notion.Page
-	for _, page := range pages {
-		if !m.state.IsProcessed(page.ID) {
-			filtered = append(filtered, page)
+// shouldMigrate applies the snapshot, title, resume, retry and change
+// filters to a page
+func (m *Migrator) shouldMigrate(page *notion.Page, f *pageFilter) bool {
+	f.found++
+
+	// Rows of snapshot databases are covered by their table memo
+	if m.snapshotFor(page.GetParentDatabaseID()) != nil {
+		f.skippedSnapshot++
+		return false
+	}
+	if f.titles != nil && !f.titles[page.GetPageTitle()] {
+		f.skippedTitle++
+		return false
+	}
+	if f.resume && m.state.IsProcessed(page.ID) {
+		f.skippedProcessed++
+		return false
+	}
+	if f.retryFailed && !m.state.IsFailed(page.ID) {
+		f.skippedNotFailed++
+		return false
+	}
+	if f.changedOnly && m.state.IsProcessed(page.ID) {
+		if record := m.state.GetPageRecord(page.ID); record != nil && record.LastEdited == page.LastEditedTime {
+			f.skippedUnchanged++
+			return false
 		}
 	}
-	return filtered
+	return true
 }
 
 // getPageCached retrieves a page with caching
-func (m *Migrator) getPageCached(pageID string) (*notion.Page, error) {
-	if cached, ok := m.pageCache[pageID]; ok {
+func (m *Migrator) getPageCached(ctx context.Context, pageID string) (*notion.Page, error) {
+	if cached, ok := m.pageCache.Get(pageID); ok {
 		return cached, nil
 	}
+	var cached notion.Page
+	if m.diskCache.Get(cache.KindPages, pageID, "", &cached) {
+		m.pageCache.Put(pageID, &cached)
+		return &cached, nil
+	}
 
-	page, err := m.notionClient.RetrievePage(pageID)
+	page, err := m.notionClient.RetrievePage(ctx, pageID)
 	if err != nil {
 		return nil, err
 	}
 
-	m.pageCache[pageID] = page
+	m.pageCache.Put(pageID, page)
+	m.putCache(cache.KindPages, pageID, page.LastEditedTime, page)
 	return page, nil
 }
 
 // getDatabaseCached retrieves a database with caching
-func (m *Migrator) getDatabaseCached(databaseID string) (*notion.Database, error) {
-	if cached, ok := m.databaseCache[databaseID]; ok {
+func (m *Migrator) getDatabaseCached(ctx context.Context, databaseID string) (*notion.Database, error) {
+	if cached, ok := m.databaseCache.Get(databaseID); ok {
 		return cached, nil
 	}
+	var cached notion.Database
+	if m.diskCache.Get(cache.KindDatabases, databaseID, "", &cached) {
+		m.databaseCache.Put(databaseID, &cached)
+		return &cached, nil
+	}
 
-	database, err := m.notionClient.RetrieveDatabase(databaseID)
+	database, err := m.notionClient.RetrieveDatabase(ctx, databaseID)
 	if err != nil {
 		return nil, err
 	}
 
-	m.databaseCache[databaseID] = database
+	m.databaseCache.Put(databaseID, database)
+	m.putCache(cache.KindDatabases, databaseID, database.LastEditedTime, database)
 	return database, nil
 }
 
-// isDatePatternTitle checks if a title matches the pattern "MM.YY <Name>"
-func isDatePatternTitle(title string) bool {
-	if len(title) < 6 {
-		return false
+// getDataSourceCached retrieves a data source with caching
+func (m *Migrator) getDataSourceCached(ctx context.Context, dataSourceID string) (*notion.DataSource, error) {
+	if cached, ok := m.dataSourceCache.Get(dataSourceID); ok {
+		return cached, nil
+	}
+	var cached notion.DataSource
+	if m.diskCache.Get(cache.KindDataSources, dataSourceID, "", &cached) {
+		m.dataSourceCache.Put(dataSourceID, &cached)
+		return &cached, nil
 	}
 
-	// Check for pattern: DD.MM or MM.YY at the start
-	// Examples: "01.25 <Name>", "08.12. <Name>", "11.10. <Name>"
-	if len(title) >= 5 {
-		// Check if starts with NN.NN pattern
-		if title[2] == '.' &&
-			(title[0] >= '0' && title[0] <= '9') &&
-			(title[1] >= '0' && title[1] <= '9') &&
-			(title[3] >= '0' && title[3] <= '9') &&
-			(title[4] >= '0' && title[4] <= '9') {
-			// Check if followed by space or dot+space
-			if len(title) > 5 && (title[5] == ' ' || (title[5] == '.' && len(title) > 6 && title[6] == ' ')) {
-				return true
-			}
-		}
+	dataSource, err := m.notionClient.RetrieveDataSource(ctx, dataSourceID)
+	if err != nil {
+		return nil, err
 	}
-	return false
+
+	m.dataSourceCache.Put(dataSourceID, dataSource)
+	m.putCache(cache.KindDataSources, dataSourceID, dataSource.LastEditedTime, dataSource)
+	return dataSource, nil
 }
 
-// getParentTagsCached retrieves parent tags with caching
-func (m *Migrator) getParentTagsCached(page *notion.Page) ([]string, error) {
-	var tags []string
+// getBlockCached retrieves a parent block with caching
+func (m *Migrator) getBlockCached(ctx context.Context, blockID string) (*notion.Block, error) {
+	if cached, ok := m.blockCache.Get(blockID); ok {
+		return cached, nil
+	}
+	var cached notion.Block
+	if m.diskCache.Get(cache.KindBlocks, blockID, "", &cached) {
+		m.blockCache.Put(blockID, &cached)
+		return &cached, nil
+	}
 
-	// Check if parent is a database
-	if dbID := page.GetParentDatabaseID(); dbID != "" {
-		database, err := m.getDatabaseCached(dbID)
-		if err == nil {
-			tags = append(tags, database.GetDatabaseTitle())
-		}
+	block, err := m.notionClient.RetrieveBlock(ctx, blockID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Walk up the page parent chain (max 10 levels to prevent infinite loops)
-	currentPageID := page.GetParentPageID()
-	for i := 0; i < 10 && currentPageID != ""; i++ {
-		parentPage, err := m.getPageCached(currentPageID)
-		if err != nil {
-			// If we can't retrieve the parent, just return what we have
-			break
-		}
+	m.blockCache.Put(blockID, block)
+	m.putCache(cache.KindBlocks, blockID, block.LastEditedTime, block)
+	return block, nil
+}
 
-		parentTitle := parentPage.GetPageTitle()
-		// Only add as tag if it doesn't match the date pattern
-		if !isDatePatternTitle(parentTitle) {
-			tags = append([]string{parentTitle}, tags...) // Prepend to maintain hierarchy
+// getBlocksCached retrieves the blocks of a page, waiting for the fetch
+// started by the prefetcher if there is one
+func (m *Migrator) getBlocksCached(ctx context.Context, page *notion.Page) ([]notion.Block, error) {
+	if fetch := m.takeBlockFetch(page.ID); fetch != nil {
+		select {
+		case <-fetch.done:
+			return fetch.blocks, fetch.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-		currentPageID = parentPage.GetParentPageID()
 	}
+	return m.fetchBlocks(ctx, page)
+}
+
+// fetchBlocks retrieves the block tree of a page, reusing the cached blocks
+// while the page is unchanged since they were fetched
+func (m *Migrator) fetchBlocks(ctx context.Context, page *notion.Page) ([]notion.Block, error) {
+	var blocks []notion.Block
+	if page.LastEditedTime != "" && m.diskCache.Get(cache.KindChildren, page.ID, page.LastEditedTime, &blocks) {
+		return blocks, nil
+	}
+
+	blocks, err := m.notionClient.RetrieveBlockTree(ctx, page.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.LastEditedTime != "" {
+		m.putCache(cache.KindChildren, page.ID, page.LastEditedTime, blocks)
+	}
+	return blocks, nil
+}
+
+// putCache writes an object to the disk cache. Failures only cost a refetch
+// on the next run, so they are logged instead of failing the migration.
+func (m *Migrator) putCache(kind cache.Kind, id, version string, v any) {
+	if err := m.diskCache.Put(kind, id, version, v); err != nil {
+		log.Printf("Warning: failed to cache %s %s: %v\n", kind, id, err)
+	}
+}
+
+// logCacheStats logs how many Notion requests the disk cache saved
+func (m *Migrator) logCacheStats() {
+	if m.diskCache == nil {
+		return
+	}
+	log.Printf("Cache: %d hits, %d misses\n", m.diskCache.Hits(), m.diskCache.Misses())
+}
+
+// getParentTagsCached derives the tags from the titles of all ancestors
+// through the tag rules, walking through databases, data sources and blocks
+// with caching
+func (m *Migrator) getParentTagsCached(ctx context.Context, parent notion.Parent) ([]string, error) {
+	ancestors, err := notion.ResolveAncestors(ctx, cachedRetriever{m}, parent)
+	return m.tagRules.apply(ancestors), err
+}
+
+// cachedRetriever resolves hierarchy objects through the Migrator's caches
+type cachedRetriever struct {
+	m *Migrator
+}
+
+func (r cachedRetriever) RetrievePage(ctx context.Context, pageID string) (*notion.Page, error) {
+	return r.m.getPageCached(ctx, pageID)
+}
+
+func (r cachedRetriever) RetrieveDatabase(ctx context.Context, databaseID string) (*notion.Database, error) {
+	return r.m.getDatabaseCached(ctx, databaseID)
+}
+
+func (r cachedRetriever) RetrieveDataSource(ctx context.Context, dataSourceID string) (*notion.DataSource, error) {
+	return r.m.getDataSourceCached(ctx, dataSourceID)
+}
 
-	return tags, nil
+func (r cachedRetriever) RetrieveBlock(ctx context.Context, blockID string) (*notion.Block, error) {
+	return r.m.getBlockCached(ctx, blockID)
 }
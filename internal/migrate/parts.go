@@ -0,0 +1,357 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/OneManRepo/notion2memos/internal/config"
+	"github.com/OneManRepo/notion2memos/internal/memos"
+)
+
+// memoCounts counts the memo writes of a run
+type memoCounts struct {
+	created   atomic.Int64
+	updated   atomic.Int64
+	recreated atomic.Int64 // recorded memos that were deleted in Memos
+	deleted   atomic.Int64 // surplus parts of pages that got shorter
+	unchanged atomic.Int64 // memos left as they were because their deterministic ID was taken
+	completed atomic.Int64 // memos of a failed run that got their display time
+	// duplicated counts pages that already had memos and got new ones
+	// because --update wasn't given
+	duplicated atomic.Int64
+}
+
+// log logs the memo writes
+func (c *memoCounts) log(update bool) {
+	if completed := c.completed.Load(); completed > 0 {
+		log.Printf("Set the display time of %d memos left incomplete by a previous run\n", completed)
+	}
+	if unchanged := c.unchanged.Load(); unchanged > 0 {
+		log.Printf("%d memos already existed under their deterministic IDs and were left unchanged; use --update to rewrite them\n", unchanged)
+	}
+	if update {
+		log.Printf("Memos: %d created, %d updated, %d recreated, %d surplus parts deleted\n",
+			c.created.Load(), c.updated.Load(), c.recreated.Load(), c.deleted.Load())
+		return
+	}
+	if duplicated := c.duplicated.Load(); duplicated > 0 {
+		log.Printf("Created new memos for %d pages that had been migrated before; use --update to update their memos instead\n", duplicated)
+	}
+}
+
+// writeMemos creates the memos of a page (one per part), each ending in its
+// source marker, and records their names in the state under the source key.
+// In update mode the recorded memos are rewritten instead: parts are patched
+// in place, new parts are created, surplus parts are deleted and memos
+// deleted in Memos are created again. Memos recorded for a page that isn't
+// processed yet were left by a failed run and are always reused, so that
+// resuming doesn't duplicate them.
+func (m *Migrator) writeMemos(ctx context.Context, memosClient *memos.Client, src memoSource, title string, contents []string, createdTime time.Time) error {
+	key := src.key
+	marked := make([]string, len(contents))
+	for i, content := range contents {
+		marked[i] = src.withMarker(content, i, len(contents))
+	}
+	contents = marked
+
+	// With deterministic IDs the names of new memos are known up front
+	ids := make([]string, len(contents))
+	if m.memoIDs && !m.dryRun {
+		for i := range ids {
+			ids[i] = src.memoID(i + 1)
+		}
+	}
+
+	var existing []string
+	if !m.dryRun {
+		existing = m.state.GetMemos(key)
+	}
+	if !m.update && len(existing) > 0 && m.state.IsProcessed(key) {
+		// Memos with deterministic IDs conflict instead of being duplicated
+		if !slices.Equal(existing, plannedNames(nil, ids)) {
+			m.written.duplicated.Add(1)
+		}
+		existing = nil
+	}
+
+	// Without recorded memos, look for memos of the page already on the
+	// server (e.g. migrated from another machine)
+	if len(existing) == 0 && m.existing != nil {
+		match, err := m.existing.match(ctx, memosClient, src, contents, createdTime)
+		if err != nil {
+			return err
+		}
+		if match != nil {
+			switch {
+			case m.existing.mode == ExistingSkip:
+				log.Printf("Memos of '%s' already exist, skipping\n", title)
+				return nil
+			case m.existing.mode == ExistingLink, match.identical:
+				m.state.SetMemos(key, match.names)
+				return nil
+			}
+			existing = match.names
+		}
+	}
+
+	// Write the parts footer along with the content if the names are known
+	bodies := contents
+	var footerNames []string
+	planned := plannedNames(existing, ids)
+	if m.splitMemos.PartsFooter && len(contents) > 1 && planned != nil && m.footerFits(contents, planned) {
+		footerNames = planned
+		bodies = make([]string, len(contents))
+		for i, content := range contents {
+			bodies[i] = withFooter(content, planned)
+		}
+	}
+
+	names := make([]string, 0, len(contents))
+	defer func() {
+		// Record what was written even if a later part failed, so that an
+		// --update run can pick up from here
+		if len(names) > 0 && names[0] != "" {
+			m.state.SetMemos(key, append(names, existing[min(len(names), len(existing)):]...))
+		}
+	}()
+
+	for i, content := range bodies {
+		partCreatedTime := partTime(createdTime, i)
+
+		recreate := false
+		if i < len(existing) {
+			err := memosClient.UpdateMemoContent(ctx, existing[i], content)
+			if err == nil {
+				names = append(names, existing[i])
+				m.written.updated.Add(1)
+				if err := m.completePendingMemo(ctx, memosClient, existing[i], partCreatedTime); err != nil {
+					return fmt.Errorf("failed to update memo part %d: %w", i+1, err)
+				}
+				continue
+			}
+			if !errors.Is(err, memos.ErrNotFound) {
+				return fmt.Errorf("failed to update memo part %d: %w", i+1, err)
+			}
+			log.Printf("Memo %s of '%s' was deleted in Memos, creating it again\n", existing[i], title)
+			m.state.RemovePendingMemo(existing[i])
+			recreate = true
+		}
+
+		name, err := memosClient.CreateMemoWithID(ctx, ids[i], content, partCreatedTime, m.dryRun)
+		var incomplete *memos.IncompleteMemoError
+		switch {
+		case errors.As(err, &incomplete):
+			// Keep the memo; the next run sets its display time
+			names = append(names, incomplete.Name)
+			m.state.AddPendingMemo(incomplete.Name, key, incomplete.DisplayTime)
+		case errors.Is(err, memos.ErrAlreadyExists) && ids[i] != "":
+			// Written by an earlier run: leave it, or rewrite it in update mode
+			name = "memos/" + ids[i]
+			if m.update {
+				if err := memosClient.UpdateMemoContent(ctx, name, content); err != nil {
+					return fmt.Errorf("failed to update memo part %d: %w", i+1, err)
+				}
+				m.written.updated.Add(1)
+			} else {
+				m.written.unchanged.Add(1)
+			}
+			names = append(names, name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create memo part %d: %w", i+1, err)
+		}
+		names = append(names, name)
+		if recreate {
+			m.written.recreated.Add(1)
+		} else {
+			m.written.created.Add(1)
+		}
+
+		if len(contents) > 1 {
+			log.Printf("Created memo part %d/%d for page '%s'\n", i+1, len(contents), title)
+		}
+	}
+
+	// Delete the parts the page no longer needs
+	if len(existing) > len(contents) {
+		for _, name := range existing[len(contents):] {
+			if err := memosClient.DeleteMemo(ctx, name); err != nil && !errors.Is(err, memos.ErrNotFound) {
+				log.Printf("Warning: failed to delete surplus memo %s of '%s': %v\n", name, title, err)
+				continue
+			}
+			m.state.RemovePendingMemo(name)
+			m.written.deleted.Add(1)
+		}
+		existing = existing[:len(contents)]
+	}
+
+	m.linkParts(ctx, memosClient, title, names, contents, footerNames)
+
+	// Tags are not critical, so failures are logged
+	if m.tags.Native && !m.dryRun {
+		if err := memosClient.RegisterTags(ctx, memos.ContentTags(strings.Join(contents, "\n"))); err != nil {
+			log.Printf("Warning: failed to register the tags of '%s': %v\n", title, err)
+		}
+	}
+
+	return nil
+}
+
+// plannedNames returns the names the parts will have: recorded names where
+// there are any, deterministic names for the rest. It returns nil if a name
+// is only known once the memo is created.
+func plannedNames(existing, ids []string) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		switch {
+		case i < len(existing):
+			names[i] = existing[i]
+		case id != "":
+			names[i] = "memos/" + id
+		default:
+			return nil
+		}
+	}
+	return names
+}
+
+// partTime returns the display time of part i: parts are offset by a few
+// seconds so that they are listed in order
+func partTime(createdTime time.Time, i int) time.Time {
+	return createdTime.Add(time.Duration(i*5) * time.Second)
+}
+
+// completePendingMemo sets the display time of a memo that a previous run
+// created without it
+func (m *Migrator) completePendingMemo(ctx context.Context, memosClient *memos.Client, name string, displayTime time.Time) error {
+	if !m.state.IsPendingMemo(name) {
+		return nil
+	}
+	if err := memosClient.SetDisplayTime(ctx, name, displayTime); err != nil {
+		return err
+	}
+	m.state.RemovePendingMemo(name)
+	m.written.completed.Add(1)
+	return nil
+}
+
+// linkParts connects the created parts of a split memo through relations
+// and the optional "Parts:" footer, unless the footer was written along with
+// the content for the same names (footerNames). The parts exist at this
+// point, so failures are logged instead of failing the page.
+func (m *Migrator) linkParts(ctx context.Context, memosClient *memos.Client, title string, names, contents, footerNames []string) {
+	// Dry runs don't create memos that could be linked
+	if len(names) < 2 || names[0] == "" {
+		return
+	}
+
+	for i, name := range names {
+		related := relatedParts(m.splitMemos.Links, names, i)
+		if len(related) == 0 {
+			continue
+		}
+		if err := memosClient.SetMemoRelations(ctx, name, related); err != nil {
+			log.Printf("Warning: failed to link part %d of '%s': %v\n", i+1, title, err)
+		}
+	}
+
+	if !m.splitMemos.PartsFooter || slices.Equal(names, footerNames) {
+		return
+	}
+	if !m.footerFits(contents, names) {
+		log.Printf("Warning: the parts footer doesn't fit into the parts of '%s', leaving it off\n", title)
+		return
+	}
+	for i, name := range names {
+		if err := memosClient.UpdateMemoContent(ctx, name, withFooter(contents[i], names)); err != nil {
+			log.Printf("Warning: failed to add parts footer to part %d of '%s': %v\n", i+1, title, err)
+		}
+	}
+}
+
+// relatedParts returns the parts that part i references
+func relatedParts(links string, names []string, i int) []string {
+	switch links {
+	case config.SplitLinksChain:
+		var related []string
+		if i > 0 {
+			related = append(related, names[i-1])
+		}
+		if i < len(names)-1 {
+			related = append(related, names[i+1])
+		}
+		return related
+	case config.SplitLinksFirst:
+		if i > 0 {
+			return []string{names[0]}
+		}
+	}
+	return nil
+}
+
+// maxMemoNameLength is the longest memo name: "memos/" and an ID of up to
+// the 32 characters Memos allows
+const maxMemoNameLength = len("memos/") + 32
+
+// footerReserve returns the room the parts footer takes in each of n parts,
+// 0 if there is no footer
+func (m *Migrator) footerReserve(n int) int {
+	if !m.splitMemos.PartsFooter || n < 2 {
+		return 0
+	}
+	return len("\n\nParts:") + n*(maxMemoNameLength+len(" [[]]"))
+}
+
+// splitWithFooter calls split with the content limit, and again with less
+// room as long as the parts footer of the resulting number of parts doesn't
+// fit. The footer grows with the number of parts, so the limit shrinks until
+// the parts don't get more. If that would leave less than minLimit, the room
+// isn't reserved and the footer is left off (see footerFits).
+func (m *Migrator) splitWithFooter(limit, minLimit int, split func(limit int) []string) []string {
+	parts := split(limit)
+	for n := len(parts); ; n = len(parts) {
+		reserve := m.footerReserve(n)
+		if reserve == 0 || limit-reserve < minLimit {
+			return parts
+		}
+		reserved := split(limit - reserve)
+		if len(reserved) <= n {
+			return reserved
+		}
+		parts = reserved
+	}
+}
+
+// footerFits reports whether the parts footer linking names fits into every
+// part without exceeding the memo length limit
+func (m *Migrator) footerFits(contents, names []string) bool {
+	for _, content := range contents {
+		if memos.ContentLength(withFooter(content, names)) > m.lengthLimit {
+			return false
+		}
+	}
+	return true
+}
+
+// withFooter appends the parts footer to a part's content
+func withFooter(content string, names []string) string {
+	return strings.TrimRight(content, " \n") + "\n\n" + partsFooter(names)
+}
+
+// partsFooter returns the line linking all parts, e.g.
+// "Parts: [[memos/a]] [[memos/b]]"
+func partsFooter(names []string) string {
+	var footer strings.Builder
+	footer.WriteString("Parts:")
+	for _, name := range names {
+		footer.WriteString(" [[" + name + "]]")
+	}
+	return footer.String()
+}
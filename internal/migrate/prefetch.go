@@ -0,0 +1,119 @@
+package migrate
+
+import (
+	"context"
+	"iter"
+
+	"github.com/OneManRepo/notion2memos/internal/cache"
+	"github.com/OneManRepo/notion2memos/internal/notion"
+)
+
+// prefetchDepth is how many upcoming pages have their blocks fetched while
+// the current page is being migrated; each additional worker adds one
+const prefetchDepth = 3
+
+// blockFetch is a block fetch started ahead of the page's migration
+type blockFetch struct {
+	done   chan struct{}
+	blocks []notion.Block
+	err    error
+}
+
+// pageSource streams the candidate pages of a run, e.g. all pages found by
+// Notion's search
+type pageSource func(ctx context.Context) iter.Seq2[notion.Page, error]
+
+// upcomingPage is a page passed from the search stream to the migration loop
+type upcomingPage struct {
+	page notion.Page
+	err  error
+}
+
+// prefetchPages streams the pages of source that pass the filter and starts
+// fetching the blocks of up to depth pages ahead of the ones being migrated.
+// Pages are yielded in source order.
+func (m *Migrator) prefetchPages(ctx context.Context, source pageSource, filter *pageFilter, depth int) iter.Seq2[notion.Page, error] {
+	return func(yield func(notion.Page, error) bool) {
+		// Block fetches outlive the stream: workers still wait for them
+		// after the last page was handed out
+		fetchCtx := ctx
+		ctx, cancel := context.WithCancel(ctx)
+		pages := make(chan upcomingPage, depth-1)
+		defer func() {
+			// Stop the producer and wait for it, so that the filter counts
+			// are final once the loop is done
+			cancel()
+			for range pages {
+			}
+		}()
+
+		go func() {
+			defer close(pages)
+			for page, err := range source(ctx) {
+				if err != nil {
+					select {
+					case pages <- upcomingPage{err: err}:
+					case <-ctx.Done():
+					}
+					return
+				}
+
+				// Search results are always current, so they refresh the
+				// cached metadata of every page, including pages only used
+				// as parents
+				m.putCache(cache.KindPages, page.ID, page.LastEditedTime, page)
+
+				if !m.shouldMigrate(&page, filter) {
+					continue
+				}
+
+				m.startBlockFetch(fetchCtx, &page)
+				select {
+				case pages <- upcomingPage{page: page}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		for upcoming := range pages {
+			if !yield(upcoming.page, upcoming.err) {
+				return
+			}
+		}
+	}
+}
+
+// startBlockFetch fetches the blocks of a page in the background; the page's
+// migration picks up the result through getBlocksCached
+func (m *Migrator) startBlockFetch(ctx context.Context, page *notion.Page) {
+	fetch := &blockFetch{done: make(chan struct{})}
+
+	m.prefetchMu.Lock()
+	m.prefetched[page.ID] = fetch
+	m.prefetchMu.Unlock()
+
+	go func() {
+		defer close(fetch.done)
+		fetch.blocks, fetch.err = m.fetchBlocks(ctx, page)
+	}()
+}
+
+// takeBlockFetch returns and forgets the background fetch of a page, if any
+func (m *Migrator) takeBlockFetch(pageID string) *blockFetch {
+	m.prefetchMu.Lock()
+	defer m.prefetchMu.Unlock()
+
+	fetch := m.prefetched[pageID]
+	delete(m.prefetched, pageID)
+	return fetch
+}
+
+// dropBlockFetches forgets the background fetches no page picked up, such as
+// those of pages still queued when a migration stopped early
+func (m *Migrator) dropBlockFetches() {
+	m.prefetchMu.Lock()
+	defer m.prefetchMu.Unlock()
+
+	clear(m.prefetched)
+}
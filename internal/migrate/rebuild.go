@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/OneManRepo/notion2memos/internal/config"
+	"github.com/OneManRepo/notion2memos/internal/memos"
+)
+
+// RebuildResult summarizes a state rebuilt from the server
+type RebuildResult struct {
+	State *config.State
+	// Memos is the number of memos carrying a source marker
+	Memos int
+	// Pages and Databases count the sources with all their parts found
+	Pages     int
+	Databases int
+	// Incomplete lists the sources with missing parts; they are recorded
+	// but not marked processed, so the next run completes them
+	Incomplete []string
+	// Ignored counts memos left out because another memo has the same
+	// source and part or a newer version of the source exists
+	Ignored int
+}
+
+// sourceParts collects the memos found for one source
+type sourceParts struct {
+	parts  int
+	edited string
+	names  map[int]string // by part
+}
+
+// sourceMap holds the memos found per source key
+type sourceMap map[string]*sourceParts
+
+// RebuildState reconstructs the page-to-memo mapping from the source markers
+// of the memos on the server. The memos of every account in the user mapping
+// are scanned. The returned state isn't saved.
+func RebuildState(ctx context.Context, cfg *config.Config, filter string) (*RebuildResult, error) {
+	tokens := []string{cfg.MemosToken}
+	if cfg.UserMappingFile != "" {
+		mapping, err := config.LoadUserMapping(cfg.UserMappingFile)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, mapping.Default.Token)
+		for _, entry := range mapping.Users {
+			tokens = append(tokens, entry.Token)
+		}
+	}
+
+	result := &RebuildResult{State: config.NewState()}
+	sources := make(sourceMap)
+	scanned := make(map[string]bool)
+	for _, token := range tokens {
+		if token == "" || scanned[token] {
+			continue
+		}
+		scanned[token] = true
+
+		client := memos.NewClient(cfg.MemosURL, token, memos.ClientOptions{})
+		list, err := client.ListMemos(ctx, memos.ListMemosOptions{Filter: filter})
+		if err != nil {
+			return nil, err
+		}
+		for _, memo := range list {
+			marker, ok := parseMarker(memo.Content)
+			if !ok {
+				continue
+			}
+			result.Memos++
+			result.Ignored += sources.add(marker, memo.Name)
+		}
+	}
+
+	keys := make([]string, 0, len(sources))
+	for key := range sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		source := sources[key]
+		names := make([]string, 0, source.parts)
+		for part := 1; part <= source.parts; part++ {
+			name, ok := source.names[part]
+			if !ok {
+				break
+			}
+			names = append(names, name)
+		}
+
+		if len(names) < source.parts {
+			result.Incomplete = append(result.Incomplete, fmt.Sprintf("%s (%d of %d parts)", key, len(names), source.parts))
+			if len(names) > 0 {
+				result.State.SetMemos(key, names)
+			}
+			continue
+		}
+
+		result.State.SetMemos(key, names)
+		result.State.MarkProcessed(key)
+		if strings.HasPrefix(key, snapshotStateKey("")) {
+			result.Databases++
+		} else {
+			// The marker tells the version, so a sync only rewrites pages
+			// edited since
+			result.State.RecordPage(key, "", source.edited, "")
+			result.Pages++
+		}
+	}
+
+	return result, nil
+}
+
+// add records a memo under its source and returns the number of memos
+// ignored because of it. Memos of a newer version of the source (by
+// last_edited_time) replace those of older versions; of two memos of the
+// same part, the first is kept.
+func (s sourceMap) add(marker parsedMarker, name string) int {
+	source, ok := s[marker.key]
+	if ok && marker.edited == source.edited && marker.parts == source.parts {
+		if _, ok := source.names[marker.part]; ok {
+			return 1
+		}
+		source.names[marker.part] = name
+		return 0
+	}
+	if ok && marker.edited <= source.edited {
+		return 1
+	}
+
+	ignored := 0
+	if ok {
+		ignored = len(source.names)
+	}
+	s[marker.key] = &sourceParts{
+		parts:  marker.parts,
+		edited: marker.edited,
+		names:  map[int]string{marker.part: name},
+	}
+	return ignored
+}
@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSourceMapAdd(t *testing.T) {
+	type memo struct {
+		name   string
+		edited string
+		part   int
+		parts  int
+	}
+	tests := []struct {
+		name  string
+		memos []memo
+		// want are the names kept by part, ignored the memos left out
+		want    map[int]string
+		edited  string
+		ignored int
+	}{
+		{"parts", []memo{{"memos/1", "v1", 1, 2}, {"memos/2", "v1", 2, 2}},
+			map[int]string{1: "memos/1", 2: "memos/2"}, "v1", 0},
+		{"parts out of order", []memo{{"memos/2", "v1", 2, 2}, {"memos/1", "v1", 1, 2}},
+			map[int]string{1: "memos/1", 2: "memos/2"}, "v1", 0},
+		{"duplicate part", []memo{{"memos/1", "v1", 1, 1}, {"memos/9", "v1", 1, 1}},
+			map[int]string{1: "memos/1"}, "v1", 1},
+		{"newer version replaces", []memo{{"memos/1", "v1", 1, 2}, {"memos/2", "v1", 2, 2}, {"memos/3", "v2", 1, 1}},
+			map[int]string{1: "memos/3"}, "v2", 2},
+		{"older version ignored", []memo{{"memos/3", "v2", 1, 1}, {"memos/1", "v1", 1, 2}, {"memos/2", "v1", 2, 2}},
+			map[int]string{1: "memos/3"}, "v2", 2},
+		{"same version split differently", []memo{{"memos/1", "v1", 1, 1}, {"memos/2", "v1", 1, 2}},
+			map[int]string{1: "memos/1"}, "v1", 1},
+	}
+
+	const key = "22222222-2222-2222-2222-222222222222"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := make(sourceMap)
+			ignored := 0
+			for _, m := range tt.memos {
+				src := memoSource{key: key, edited: m.edited}
+				marker, ok := parseMarker(src.withMarker("# Roadmap", m.part-1, m.parts))
+				if !ok {
+					t.Fatal("marker not found")
+				}
+				ignored += sources.add(marker, m.name)
+			}
+
+			source := sources[key]
+			if !reflect.DeepEqual(source.names, tt.want) || source.edited != tt.edited || ignored != tt.ignored {
+				t.Errorf("got %v of %s with %d ignored, want %v of %s with %d ignored",
+					source.names, source.edited, ignored, tt.want, tt.edited, tt.ignored)
+			}
+		})
+	}
+}
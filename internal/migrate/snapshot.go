@@ -0,0 +1,292 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/OneManRepo/notion2memos/internal/config"
+	"github.com/OneManRepo/notion2memos/internal/notion"
+)
+
+// snapshotDatabase is a database rendered as a table memo
+type snapshotDatabase struct {
+	config   config.DatabaseSnapshot
+	database *notion.Database
+}
+
+// resolveSnapshots looks up the databases configured for snapshot mode
+func (m *Migrator) resolveSnapshots(ctx context.Context) error {
+	m.snapshots = make(map[string]*snapshotDatabase)
+
+	for _, snapshotCfg := range m.snapshotConfigs {
+		database, err := m.findDatabase(ctx, snapshotCfg.Database)
+		if err != nil {
+			return fmt.Errorf("failed to resolve snapshot database %q: %w", snapshotCfg.Database, err)
+		}
+		m.snapshots[normalizeID(database.ID)] = &snapshotDatabase{
+			config:   snapshotCfg,
+			database: database,
+		}
+	}
+
+	return nil
+}
+
+// findDatabase retrieves a database by ID or exact title
+func (m *Migrator) findDatabase(ctx context.Context, idOrTitle string) (*notion.Database, error) {
+	if isNotionID(idOrTitle) {
+		return m.getDatabaseCached(ctx, idOrTitle)
+	}
+
+	dataSources, err := m.notionClient.SearchDataSources(ctx, idOrTitle)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dataSource := range dataSources {
+		dbID := dataSource.GetParentDatabaseID()
+		if dbID == "" {
+			continue
+		}
+		database, err := m.getDatabaseCached(ctx, dbID)
+		if err != nil {
+			return nil, err
+		}
+		if database.GetDatabaseTitle() == idOrTitle || dataSource.GetDataSourceTitle() == idOrTitle {
+			return database, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no database titled %q is shared with the integration", idOrTitle)
+}
+
+// snapshotFor returns the snapshot configuration of a database, if any
+func (m *Migrator) snapshotFor(databaseID string) *snapshotDatabase {
+	if databaseID == "" {
+		return nil
+	}
+	return m.snapshots[normalizeID(databaseID)]
+}
+
+// snapshotTable queries all rows of a snapshot database and returns the
+// columns and rows of its table
+func (m *Migrator) snapshotTable(ctx context.Context, snapshot *snapshotDatabase) ([]string, []notion.Page, error) {
+	var rows []notion.Page
+	var columns []string
+
+	for _, ref := range snapshot.database.DataSources {
+		dataSourceRows, err := m.notionClient.QueryDataSource(ctx, ref.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to query data source %s: %w", ref.ID, err)
+		}
+		rows = append(rows, dataSourceRows...)
+
+		if columns == nil && len(snapshot.config.Properties) == 0 {
+			dataSource, err := m.notionClient.RetrieveDataSource(ctx, ref.ID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to retrieve data source %s: %w", ref.ID, err)
+			}
+			columns = dataSource.PropertyNames()
+		}
+	}
+
+	if len(snapshot.config.Properties) > 0 {
+		columns = snapshot.config.Properties
+	}
+
+	return columns, rows, nil
+}
+
+// renderInlineSnapshots renders the inline databases of a page that are
+// configured for inline snapshots, including those nested in columns,
+// toggles and other blocks
+func (m *Migrator) renderInlineSnapshots(ctx context.Context, blocks []notion.Block) (map[string]string, error) {
+	rendered := make(map[string]string)
+	if err := m.renderNestedSnapshots(ctx, blocks, rendered); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// renderNestedSnapshots adds the inline snapshots among blocks and their
+// children to rendered
+func (m *Migrator) renderNestedSnapshots(ctx context.Context, blocks []notion.Block, rendered map[string]string) error {
+	for _, block := range blocks {
+		if err := m.renderNestedSnapshots(ctx, block.Children, rendered); err != nil {
+			return err
+		}
+		if block.Type != "child_database" {
+			continue
+		}
+		snapshot := m.snapshotFor(block.ID)
+		if snapshot == nil || !snapshot.config.Inline {
+			continue
+		}
+
+		columns, rows, err := m.snapshotTable(ctx, snapshot)
+		if err != nil {
+			return err
+		}
+
+		var md strings.Builder
+		if block.ChildDatabase != nil && block.ChildDatabase.Title != "" {
+			md.WriteString("## " + block.ChildDatabase.Title + "\n\n")
+		}
+		md.WriteString(notion.RenderTable(columns, rows))
+		rendered[block.ID] = md.String()
+	}
+
+	return nil
+}
+
+// migrateSnapshots creates the table memos of all standalone snapshot
+// databases. With a non-zero since, snapshots that have memos are only
+// rendered again if the database or one of its rows was edited since then.
+func (m *Migrator) migrateSnapshots(ctx context.Context, opts MigrateOptions, since time.Time) error {
+	for _, snapshot := range m.standaloneSnapshots(opts.FilterTitles) {
+		title := snapshot.database.GetDatabaseTitle()
+		stateKey := snapshotStateKey(snapshot.database.ID)
+		if opts.Resume && m.state.IsProcessed(stateKey) {
+			log.Printf("Skipping already processed database snapshot: %s\n", title)
+			continue
+		}
+		if opts.RetryFailed && !m.state.IsFailed(stateKey) {
+			continue
+		}
+
+		written, err := m.migrateSnapshot(ctx, snapshot, since)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("migration interrupted: %w", ctx.Err())
+			}
+			if opts.ContinueOnError && !isFatal(err) {
+				m.recordFailure(stateKey, title, err)
+				log.Printf("Failed database snapshot '%s': %v\n", title, err)
+				if err := m.state.SaveState(); err != nil {
+					return fmt.Errorf("failed to save state: %w", err)
+				}
+				continue
+			}
+			if saveErr := m.state.SaveState(); saveErr != nil {
+				log.Printf("Warning: failed to save state: %v\n", saveErr)
+			}
+			return fmt.Errorf("failed to migrate database snapshot %s (%s): %w", title, snapshot.database.ID, err)
+		}
+		if !written {
+			log.Printf("Skipping database snapshot '%s': no rows edited since the last sync\n", title)
+			continue
+		}
+
+		m.state.MarkProcessed(stateKey)
+		if err := m.state.SaveState(); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// standaloneSnapshots returns the snapshot databases that get memos of their
+// own, sorted by title. With titles only the databases with these titles are
+// returned.
+func (m *Migrator) standaloneSnapshots(titles []string) []*snapshotDatabase {
+	var snapshots []*snapshotDatabase
+	for _, snapshot := range m.snapshots {
+		title := snapshot.database.GetDatabaseTitle()
+		if !snapshot.config.Inline && (len(titles) == 0 || slices.Contains(titles, title)) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	slices.SortFunc(snapshots, func(a, b *snapshotDatabase) int {
+		return strings.Compare(a.database.GetDatabaseTitle(), b.database.GetDatabaseTitle())
+	})
+	return snapshots
+}
+
+// migrateSnapshot renders one database as table memo(s) and creates them,
+// and reports whether it did. Snapshots with memos that weren't edited since
+// a non-zero since are left as they are.
+func (m *Migrator) migrateSnapshot(ctx context.Context, snapshot *snapshotDatabase, since time.Time) (bool, error) {
+	title := snapshot.database.GetDatabaseTitle()
+	columns, rows, err := m.snapshotTable(ctx, snapshot)
+	if err != nil {
+		return false, err
+	}
+	stateKey := snapshotStateKey(snapshot.database.ID)
+	if !since.IsZero() && len(m.state.GetMemos(stateKey)) > 0 && !snapshotEdited(snapshot.database, rows, since) {
+		return false, nil
+	}
+
+	tags, err := m.getParentTagsCached(ctx, snapshot.database.Parent)
+	if err != nil {
+		log.Printf("Warning: failed to retrieve parent tags for database %s: %v\n", title, err)
+	}
+
+	parts := m.splitWithFooter(m.contentLimit(), m.contentLimit()/2, func(limit int) []string {
+		return notion.DatabaseToMarkdown(title, tags, columns, rows, limit, m.tagOptions())
+	})
+	log.Printf("Rendering database '%s' (%d rows) as %d snapshot memo(s)\n", title, len(rows), len(parts))
+
+	createdTime, err := time.Parse(time.RFC3339, snapshot.database.CreatedTime)
+	if err != nil {
+		createdTime = time.Now()
+	}
+
+	// Like pages, a snapshot is finished once its first memo was created
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	src := memoSource{key: stateKey, edited: snapshot.database.LastEditedTime}
+	if err := m.writeMemos(ctx, m.memosClient, src, title, parts, createdTime); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// snapshotEdited reports whether a database or one of its rows was edited at
+// or after since. Deleted rows leave no trace; a full sync picks them up.
+func snapshotEdited(database *notion.Database, rows []notion.Page, since time.Time) bool {
+	edited := func(timestamp string) bool {
+		t, err := time.Parse(time.RFC3339, timestamp)
+		return err != nil || !t.Before(since)
+	}
+	if edited(database.LastEditedTime) {
+		return true
+	}
+	for _, row := range rows {
+		if edited(row.LastEditedTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotStateKey is the state key recording a migrated database snapshot
+func snapshotStateKey(databaseID string) string {
+	return "database:" + databaseID
+}
+
+// normalizeID strips the dashes from a Notion ID
+func normalizeID(id string) string {
+	return strings.ReplaceAll(strings.ToLower(id), "-", "")
+}
+
+// isNotionID reports whether s looks like a Notion ID (32 hex digits)
+func isNotionID(s string) bool {
+	id := normalizeID(s)
+	if len(id) != 32 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
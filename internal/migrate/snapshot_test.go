@@ -0,0 +1,41 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInlineSnapshotNested(t *testing.T) {
+	const (
+		kickoffID  = "66666666-6666-6666-6666-666666666666"
+		toggleID   = "e0000000-0000-0000-0000-000000000001"
+		databaseID = "44444444-4444-4444-4444-444444444444"
+	)
+	env := newTestEnv(t)
+	env.writeFixture("blocks/"+kickoffID+".json", []map[string]any{
+		{"object": "block", "id": "e0000000-0000-0000-0000-000000000000", "type": "paragraph", "has_children": false,
+			"paragraph": map[string]any{"rich_text": []map[string]any{{"type": "text", "plain_text": "Agreed on the Q2 scope."}}}},
+		{"object": "block", "id": toggleID, "type": "toggle", "has_children": true,
+			"toggle": map[string]any{"rich_text": []map[string]any{{"type": "text", "plain_text": "Diary"}}}},
+	})
+	env.writeFixture("blocks/"+toggleID+".json", []map[string]any{
+		{"object": "block", "id": databaseID, "type": "child_database", "has_children": false,
+			"child_database": map[string]any{"title": "Tagebuch"}},
+	})
+
+	m := env.migrator(false, "database_snapshots:\n  - database: Tagebuch\n    inline: true\n    properties: [Name, Mood]\n")
+	if err := m.Migrate(context.Background(), MigrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	memos := env.memosOf(kickoffID)
+	if len(memos) != 1 {
+		t.Fatalf("got %d memos of the kickoff page, want 1", len(memos))
+	}
+	for _, want := range []string{"Agreed on the Q2 scope.", "## Tagebuch", "| Name | Mood |", "| A quiet evening | Calm |"} {
+		if !strings.Contains(memos[0].Content, want) {
+			t.Errorf("memo doesn't contain %q:\n%s", want, memos[0].Content)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package migrate
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/OneManRepo/notion2memos/internal/config"
+)
+
+// Markers connecting the parts of split memos that aren't linked through
+// relations
+const (
+	splitMarker        = "\n\n..."
+	continuationMarker = "...\n\n"
+)
+
+// minPartBody is the least room left for the body of a part, even if the
+// title and tags take up most of a memo
+const minPartBody = 1024
+
+var (
+	// headingPattern matches a Markdown heading line
+	headingPattern = regexp.MustCompile(`^#{1,6} `)
+	// tableSeparatorPattern matches the line under a table's header row
+	tableSeparatorPattern = regexp.MustCompile(`^\s*\|[\s:|-]+\|?\s*$`)
+)
+
+// splitMemo splits a long memo into numbered parts. Every part gets the
+// title with its number and the tag lines: titleTags under the title and
+// endTags at the end, as they were placed in content. The body is split
+// between blocks, preferring to start a part at a heading. Blocks longer than
+// a part are split between lines, closing and reopening code fences and
+// repeating table headers; lines longer than a part are split between words.
+// Parts are never cut inside a UTF-8 character. Lengths are in bytes, which
+// is how Memos measures them (see memos.ContentLength).
+func (m *Migrator) splitMemo(content, pageTitle, titleTags, endTags string) []string {
+	// Parts linked through relations don't need text markers
+	markers := m.splitMemos.Links == config.SplitLinksNone
+
+	// Take off what every part repeats and reserve room for it
+	body := strings.TrimPrefix(content, "# "+pageTitle+"\n\n")
+	overhead := len("# " + pageTitle + " (99/99)\n\n")
+	if titleTags != "" {
+		if line, rest, _ := strings.Cut(body, "\n"); strings.TrimSpace(line) == titleTags {
+			body = strings.TrimLeft(rest, "\n")
+		}
+		overhead += len(titleTags) + 2
+	}
+	if endTags != "" {
+		body = strings.TrimSuffix(body, "\n\n"+endTags)
+		overhead += len(endTags) + 2
+	}
+	if markers {
+		overhead += len(splitMarker) + len(continuationMarker)
+	}
+
+	bodies := m.splitWithFooter(m.contentLimit()-overhead, minPartBody, func(budget int) []string {
+		return splitBody(body, max(budget, minPartBody))
+	})
+	log.Printf("Split page '%s' into %d parts\n", pageTitle, len(bodies))
+
+	contents := make([]string, len(bodies))
+	for i, part := range bodies {
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s (%d/%d)\n\n", pageTitle, i+1, len(bodies))
+		if titleTags != "" {
+			b.WriteString(titleTags + "\n\n")
+		}
+		if markers && i > 0 {
+			b.WriteString(continuationMarker)
+		}
+		b.WriteString(part)
+		if markers && i < len(bodies)-1 {
+			b.WriteString(splitMarker)
+		}
+		if endTags != "" {
+			b.WriteString("\n\n" + endTags)
+		}
+		contents[i] = b.String()
+	}
+	return contents
+}
+
+// splitBody splits Markdown into parts of at most budget bytes. A part ends
+// before the block that doesn't fit anymore, or before its last heading if
+// that keeps it at least half full.
+func splitBody(body string, budget int) []string {
+	var parts, current []string
+	size := 0
+	flush := func() {
+		if len(current) > 0 {
+			parts = append(parts, strings.Join(current, "\n\n"))
+			current, size = nil, 0
+		}
+	}
+	fits := func(block string) bool {
+		if len(current) == 0 {
+			return len(block) <= budget
+		}
+		return size+2+len(block) <= budget
+	}
+	add := func(block string) {
+		if len(current) > 0 {
+			size += 2
+		}
+		current = append(current, block)
+		size += len(block)
+	}
+
+	for _, block := range markdownBlocks(body) {
+		if fits(block) {
+			add(block)
+			continue
+		}
+
+		// An oversized block fills up the current part if there's room for
+		// at least one line segment (half a part, see splitBlock)
+		if len(block) > budget {
+			room := budget - size - 2
+			if len(current) == 0 || room < budget/2 {
+				flush()
+				room = budget
+			}
+			pieces := splitBlock(block, room, budget)
+			add(pieces[0])
+			flush()
+			parts = append(parts, pieces[1:len(pieces)-1]...)
+			add(pieces[len(pieces)-1])
+			continue
+		}
+
+		var carried []string
+		for i := len(current) - 1; i > 0; i-- {
+			if headingPattern.MatchString(current[i]) {
+				if len(strings.Join(current[:i], "\n\n")) >= budget/2 {
+					carried = current[i:]
+					current = current[:i]
+				}
+				break
+			}
+		}
+		flush()
+		for _, carriedBlock := range carried {
+			add(carriedBlock)
+		}
+		if !fits(block) {
+			flush()
+		}
+		add(block)
+	}
+	flush()
+
+	return parts
+}
+
+// markdownBlocks splits Markdown into the blocks between blank lines. Code
+// fences are kept whole, including blank lines inside them.
+func markdownBlocks(markdown string) []string {
+	var blocks, lines []string
+	fence := ""
+	for _, line := range strings.Split(markdown, "\n") {
+		if fence == "" && strings.TrimSpace(line) == "" {
+			if len(lines) > 0 {
+				blocks = append(blocks, strings.Join(lines, "\n"))
+				lines = nil
+			}
+			continue
+		}
+		lines = append(lines, line)
+		fence = fenceAfter(fence, line)
+	}
+	if len(lines) > 0 {
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	return blocks
+}
+
+// splitBlock splits a block longer than budget between lines into a first
+// piece of at most first bytes and further pieces of at most budget bytes. A
+// code fence open at a cut is closed and opened again in the next piece, and
+// a table's header is repeated. Lines are split further if needed.
+func splitBlock(block string, first, budget int) []string {
+	lines := strings.Split(block, "\n")
+
+	header := ""
+	if len(lines) > 2 && strings.HasPrefix(strings.TrimSpace(lines[0]), "|") && tableSeparatorPattern.MatchString(lines[1]) {
+		header = lines[0] + "\n" + lines[1]
+		if len(header) > budget/4 {
+			header = ""
+		}
+	}
+
+	var pieces []string
+	var current strings.Builder
+	limit := first
+	fence := ""
+	for i, line := range lines {
+		next := fenceAfter(fence, line)
+		closing := 0
+		if next != "" {
+			closing = len(closingFence(next)) + 1
+		}
+
+		// Segments are at most half a part, so that a reopened fence or a
+		// repeated header always fits in front of them
+		for _, segment := range splitLine(line, budget/2) {
+			if current.Len() > 0 && current.Len()+1+len(segment)+closing > limit {
+				piece := current.String()
+				if fence != "" {
+					piece += "\n" + closingFence(fence)
+				}
+				pieces = append(pieces, piece)
+				current.Reset()
+				limit = budget
+
+				switch {
+				case fence != "":
+					current.WriteString(fence)
+				case header != "" && i >= 2:
+					current.WriteString(header)
+				}
+			}
+			if current.Len() > 0 {
+				current.WriteByte('\n')
+			}
+			current.WriteString(segment)
+		}
+		fence = next
+	}
+	if current.Len() > 0 {
+		pieces = append(pieces, current.String())
+	}
+	return pieces
+}
+
+// splitLine splits a line into segments of at most limit bytes, preferably
+// at a space and never inside a UTF-8 character
+func splitLine(line string, limit int) []string {
+	var segments []string
+	for len(line) > limit {
+		if cut := strings.LastIndexByte(line[:limit+1], ' '); cut >= limit/2 {
+			segments = append(segments, line[:cut])
+			line = line[cut+1:]
+			continue
+		}
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		segments = append(segments, line[:cut])
+		line = line[cut:]
+	}
+	return append(segments, line)
+}
+
+// fenceAfter returns the opening line of the code fence open after line,
+// given the one open before it ("" if none). Fences are runs of at least
+// three backticks, possibly indented in lists.
+func fenceAfter(open, line string) string {
+	ticks, rest := backticks(line)
+	if open == "" {
+		if ticks >= 3 {
+			return line
+		}
+		return ""
+	}
+	if openTicks, _ := backticks(open); ticks >= openTicks && strings.TrimSpace(rest) == "" {
+		return ""
+	}
+	return open
+}
+
+// closingFence returns the line closing the code fence opened by open
+func closingFence(open string) string {
+	indent := open[:len(open)-len(strings.TrimLeft(open, " \t"))]
+	ticks, _ := backticks(open)
+	return indent + strings.Repeat("`", ticks)
+}
+
+// backticks returns the number of backticks a line starts with after its
+// indentation, and the rest of the line
+func backticks(line string) (int, string) {
+	trimmed := strings.TrimLeft(line, " \t")
+	rest := strings.TrimLeft(trimmed, "`")
+	return len(trimmed) - len(rest), rest
+}
@@ -0,0 +1,151 @@
+package migrate
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitBody(t *testing.T) {
+	paragraph := func(word string, n int) string {
+		return strings.TrimSpace(strings.Repeat(word+" ", n))
+	}
+	fence := func(lang string, lines int) string {
+		var b strings.Builder
+		b.WriteString("```" + lang)
+		for i := range lines {
+			fmt.Fprintf(&b, "\nline %d of the code block", i)
+		}
+		return b.String() + "\n```"
+	}
+	table := func(rows int) string {
+		var b strings.Builder
+		b.WriteString("| Name | Value |\n| --- | --- |")
+		for i := range rows {
+			fmt.Fprintf(&b, "\n| row %d | %s |", i, strings.Repeat("x", 40))
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		budget int
+		// minParts is the least number of parts expected
+		minParts int
+	}{
+		{"fits", "# Heading\n\nshort paragraph", 2000, 1},
+		{"paragraphs", strings.Repeat(paragraph("word", 50)+"\n\n", 40), 2000, 5},
+		{"oversized block after a short one", strings.Repeat("a", 1400) + "\n\n" + strings.Repeat("b", 3000), 2000, 3},
+		{"oversized block after a half full part", strings.Repeat("a", 900) + "\n\n" + strings.Repeat("b ", 2000), 2000, 3},
+		{"long line without spaces", strings.Repeat("z", 9000), 2000, 5},
+		{"multi-byte characters", strings.Repeat("äöü€😀", 2000), 2000, 8},
+		{"multi-byte words", strings.Repeat(paragraph("Grüße", 300)+"\n\n", 5), 1500, 5},
+		{"code fence", "intro\n\n" + fence("go", 300) + "\n\noutro", 2000, 4},
+		{"code fence with blank lines", fence("", 50) + "\n\n\n" + fence("sh", 200), 1500, 4},
+		{"nested code fence", "- item\n\n  ```go\n" + strings.Repeat("  x := 1\n", 400) + "  ```", 1200, 3},
+		{"table", table(200), 2000, 5},
+		{"headings", strings.Repeat("## Section\n\n"+paragraph("text", 100)+"\n\n", 20), 2000, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitBody(tt.body, tt.budget)
+			if len(parts) < tt.minParts {
+				t.Errorf("got %d parts, want at least %d", len(parts), tt.minParts)
+			}
+			checkParts(t, parts, tt.budget)
+		})
+	}
+}
+
+func TestSplitBodyKeepsHeadingWithSection(t *testing.T) {
+	body := strings.Repeat("x", 1200) + "\n\n## Next\n\n" + strings.Repeat("y", 500) + "\n\n" + strings.Repeat("z", 900)
+	parts := splitBody(body, 2000)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], "## Next") {
+		t.Fatalf("want the second part to start at the heading, got %q", parts)
+	}
+}
+
+func TestSplitBlockRepeatsTableHeader(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("| A | B |\n|---|---|")
+	for i := range 100 {
+		fmt.Fprintf(&b, "\n| %d | %s |", i, strings.Repeat("v", 30))
+	}
+	parts := splitBody(b.String(), 1000)
+	if len(parts) < 2 {
+		t.Fatalf("want several parts, got %d", len(parts))
+	}
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "| A | B |\n|---|---|\n") {
+			t.Errorf("part %d doesn't start with the table header: %q", i+1, part[:min(len(part), 40)])
+		}
+	}
+}
+
+func TestSplitBodyRandom(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	words := []string{"a", "word", "Grüße", "€uro", "😀", "`tick`", "longerword", "-", "|"}
+	line := func() string {
+		var b strings.Builder
+		for range rng.IntN(60) {
+			b.WriteString(words[rng.IntN(len(words))] + " ")
+		}
+		return b.String()
+	}
+
+	for i := range 300 {
+		var b strings.Builder
+		for range rng.IntN(40) {
+			switch rng.IntN(5) {
+			case 0:
+				b.WriteString("```\n")
+				for range rng.IntN(80) {
+					b.WriteString(line() + "\n")
+				}
+				b.WriteString("```")
+			case 1:
+				b.WriteString("| h1 | h2 |\n| --- | --- |")
+				for range rng.IntN(80) {
+					b.WriteString("\n| " + line() + " | x |")
+				}
+			case 2:
+				b.WriteString("## " + line())
+			default:
+				for range rng.IntN(10) + 1 {
+					b.WriteString(line() + "\n")
+				}
+			}
+			b.WriteString("\n\n")
+		}
+
+		budget := 600 + rng.IntN(3000)
+		t.Run(fmt.Sprintf("seed %d budget %d", i, budget), func(t *testing.T) {
+			checkParts(t, splitBody(b.String(), budget), budget)
+		})
+	}
+}
+
+// checkParts checks that the parts fit the budget, are valid UTF-8 and
+// close every code fence they open
+func checkParts(t *testing.T, parts []string, budget int) {
+	t.Helper()
+	for i, part := range parts {
+		if len(part) > budget {
+			t.Errorf("part %d is %d bytes, budget %d", i+1, len(part), budget)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("part %d is not valid UTF-8", i+1)
+		}
+		fence := ""
+		for _, line := range strings.Split(part, "\n") {
+			fence = fenceAfter(fence, line)
+		}
+		if fence != "" {
+			t.Errorf("part %d leaves the code fence %q open", i+1, fence)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"iter"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/OneManRepo/notion2memos/internal/notion"
+	"github.com/schollz/progressbar/v3"
+)
+
+// syncOverlap is how long before the start of the last sync a sync looks for
+// edits. Notion rounds last_edited_time to the minute, and pages edited
+// while the last sync ran may have been listed before the edit.
+const syncOverlap = 5 * time.Minute
+
+// SyncOptions contains options for syncing
+type SyncOptions struct {
+	// Full scans all pages instead of stopping at the pages edited before
+	// the last sync; only full scans flag deleted pages
+	Full bool
+	// Workers is the number of pages synced concurrently (at least 1)
+	Workers int
+	// ContinueOnError records failed pages and goes on with the next ones;
+	// Sync then returns ErrPartialSuccess
+	ContinueOnError bool
+}
+
+// syncCounts counts the pages written by a sync
+type syncCounts struct {
+	created   atomic.Int64 // pages without memos so far
+	updated   atomic.Int64 // edited pages whose memos were rewritten
+	unchanged atomic.Int64 // edited pages that render to the same Markdown
+}
+
+// count counts a written page; existed tells whether it had memos before
+func (c *syncCounts) count(existed bool) {
+	if existed {
+		c.updated.Add(1)
+	} else {
+		c.created.Add(1)
+	}
+}
+
+// log logs the pages written by the sync
+func (c *syncCounts) log() {
+	log.Printf("Pages: %d new, %d updated, %d edited without changing their memos\n",
+		c.created.Load(), c.updated.Load(), c.unchanged.Load())
+}
+
+// Sync brings Memos up to date with the pages edited in Notion since the
+// last sync: new pages get memos, and pages edited since they were migrated
+// get their memos updated. A full scan, which the first sync always is, also
+// flags recorded pages that are no longer in Notion; their memos are kept.
+func (m *Migrator) Sync(ctx context.Context, opts SyncOptions) error {
+	log.Println("Syncing pages edited in Notion...")
+
+	if m.dryRun {
+		log.Println("DRY RUN MODE: Memos will be saved to ./dry-run-output/ instead of being created")
+	}
+	m.update = true
+	m.synced = &syncCounts{}
+
+	if err := m.checkServer(ctx); err != nil {
+		return err
+	}
+	if err := m.resolveSnapshots(ctx); err != nil {
+		return err
+	}
+
+	started := time.Now()
+	var since time.Time
+	if last := m.state.LastSync(); !opts.Full && !last.IsZero() {
+		since = last.Add(-syncOverlap)
+		log.Printf("Looking for pages edited since %s\n", since.Local().Format(time.DateTime))
+	} else {
+		log.Println("Scanning all pages")
+	}
+
+	// Snapshot databases are rendered again if a row was edited; their rows
+	// are left out of the page search below
+	err := m.migrateSnapshots(ctx, MigrateOptions{ContinueOnError: opts.ContinueOnError}, since)
+	if ctx.Err() != nil {
+		return m.syncInterrupted(ctx, 0)
+	}
+	if err != nil {
+		m.logFailed()
+		return err
+	}
+
+	// The search is sorted by last_edited_time, so it stops at the first
+	// page edited before the cutoff
+	seen := make(map[string]bool)
+	source := func(ctx context.Context) iter.Seq2[notion.Page, error] {
+		return m.editedSince(ctx, since, seen)
+	}
+	filter := &pageFilter{changedOnly: true}
+	bar := progressbar.Default(-1, "Syncing pages")
+
+	synced, err := m.migratePages(ctx, source, filter, MigrateOptions{
+		Workers:         opts.Workers,
+		ContinueOnError: opts.ContinueOnError,
+	}, bar)
+	if ctx.Err() != nil {
+		bar.Close()
+		return m.syncInterrupted(ctx, synced)
+	}
+	if err != nil {
+		bar.Close()
+		m.logFailed()
+		return err
+	}
+	bar.Finish()
+
+	filter.logSummary()
+	if since.IsZero() {
+		m.flagDeleted(seen)
+	}
+	// Pages that failed weren't recorded, so the next sync looks as far
+	// back as this one did
+	if len(m.failed) == 0 && !m.dryRun {
+		m.state.SetLastSync(started)
+	}
+	if err := m.state.SaveState(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	log.Printf("\nSync completed! Synced %d pages\n", synced)
+	m.synced.log()
+	m.written.log(m.update)
+	m.logSkipped()
+	m.authors.logReport()
+
+	if len(m.failed) > 0 {
+		m.logFailed()
+		log.Println("Run 'notion2memos sync' again to retry the failed pages.")
+		return fmt.Errorf("%w: %d pages failed", ErrPartialSuccess, len(m.failed))
+	}
+	return nil
+}
+
+// syncInterrupted persists the state after the sync was cancelled and
+// prints how to continue it
+func (m *Migrator) syncInterrupted(ctx context.Context, synced int) error {
+	if err := m.state.SaveState(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	log.Printf("\nSync interrupted after syncing %d pages\n", synced)
+	log.Println("State has been saved. Run 'notion2memos sync' again to continue.")
+	return fmt.Errorf("sync interrupted: %w", ctx.Err())
+}
+
+// editedSince yields the pages edited at or after since, most recently
+// edited first, or all pages if since is zero. The IDs of the yielded pages
+// are added to seen, and pages flagged as deleted that turn up again are no
+// longer flagged.
+func (m *Migrator) editedSince(ctx context.Context, since time.Time, seen map[string]bool) iter.Seq2[notion.Page, error] {
+	return func(yield func(notion.Page, error) bool) {
+		for page, err := range m.notionClient.SearchEditedPagesIter(ctx) {
+			if err != nil {
+				yield(page, err)
+				return
+			}
+			if edited, err := time.Parse(time.RFC3339, page.LastEditedTime); err == nil && edited.Before(since) {
+				return
+			}
+
+			seen[page.ID] = true
+			if m.state.MarkDeleted(page.ID, false) {
+				log.Printf("Page '%s' is back in Notion\n", page.GetPageTitle())
+			}
+			if !yield(page, nil) {
+				return
+			}
+		}
+	}
+}
+
+// flagDeleted flags the recorded pages a full scan didn't find: they were
+// deleted in Notion or are no longer shared with the integration
+func (m *Migrator) flagDeleted(seen map[string]bool) {
+	ids := m.state.RecordedPageIDs()
+	sort.Strings(ids)
+
+	var flagged []string
+	for _, id := range ids {
+		if seen[id] || !m.state.MarkDeleted(id, true) {
+			continue
+		}
+		title := id
+		if record := m.state.GetPageRecord(id); record != nil && record.Title != "" {
+			title = record.Title
+		}
+		if names := m.state.GetMemos(id); len(names) > 0 {
+			title += " (" + strings.Join(names, ", ") + ")"
+		}
+		flagged = append(flagged, title)
+	}
+
+	if len(flagged) == 0 {
+		return
+	}
+	log.Printf("%d pages are no longer in Notion; they are flagged in the state and their memos are kept:\n", len(flagged))
+	for _, title := range flagged {
+		log.Printf("  %s\n", title)
+	}
+}
+
+// keepUnchanged records the edit time of a page that still renders to the
+// Markdown its memos were written from, and reports whether it did
+func (m *Migrator) keepUnchanged(page *notion.Page, hash string) bool {
+	record := m.state.GetPageRecord(page.ID)
+	if record == nil || record.ContentHash != hash || len(m.state.GetMemos(page.ID)) == 0 {
+		return false
+	}
+	m.recordPage(page, hash)
+	m.synced.unchanged.Add(1)
+	return true
+}
+
+// contentHash returns the hash of a page's Markdown recorded in the state
+func contentHash(markdown string) string {
+	sum := sha256.Sum256([]byte(markdown))
+	return hex.EncodeToString(sum[:])
+}
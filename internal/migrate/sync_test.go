@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncAfterDryRun(t *testing.T) {
+	const roadmapID = "22222222-2222-2222-2222-222222222222"
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.migrator(false, "").Sync(ctx, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	before := env.memosOf(roadmapID)
+	if len(before) != 1 {
+		t.Fatalf("got %d memos of the roadmap, want 1", len(before))
+	}
+
+	edited := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	env.editFixture("pages/roadmap.json", "2024-03-06T08:15:00.000Z", edited)
+	env.editFixture("blocks/"+roadmapID+".json", "Ship the importer", "Ship the importer in May")
+
+	// A dry run leaves the state as the next real sync needs it
+	if err := env.migrator(true, "").Sync(ctx, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.migrator(false, "").Sync(ctx, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	after := env.memosOf(roadmapID)
+	if len(after) != 1 || after[0].Name != before[0].Name {
+		t.Fatalf("want memo %s updated, got %d memos", before[0].Name, len(after))
+	}
+	if !strings.Contains(after[0].Content, "Ship the importer in May") {
+		t.Errorf("memo wasn't updated:\n%s", after[0].Content)
+	}
+	if !strings.Contains(after[0].Content, "edited="+edited) {
+		t.Errorf("memo marker doesn't carry the new edit time:\n%s", after[0].Content)
+	}
+}
@@ -0,0 +1,212 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/OneManRepo/notion2memos/internal/config"
+	"github.com/OneManRepo/notion2memos/internal/notion"
+)
+
+// tagEntry is a tag on its way through the tag rules
+type tagEntry struct {
+	name   string
+	parent *notion.Ancestor // nil for tags added by rules
+}
+
+// tagRule is a config.TagRule with its regular expression compiled
+type tagRule struct {
+	config.TagRule
+	pattern *regexp.Regexp // of Replace or Drop
+}
+
+// tagRules turns the parents of pages into their tags
+type tagRules []tagRule
+
+// newTagRules compiles the configured tag rules
+func newTagRules(rules []config.TagRule) (tagRules, error) {
+	compiled := make(tagRules, len(rules))
+	for i, rule := range rules {
+		compiled[i].TagRule = rule
+
+		pattern := rule.Drop
+		if rule.Replace != nil {
+			pattern = rule.Replace.Pattern
+		}
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("tags.rules[%d]: invalid regular expression: %w", i, err)
+		}
+		compiled[i].pattern = re
+	}
+	return compiled, nil
+}
+
+// apply returns the tags of an object with the given ancestors (outermost
+// first). Tags that end up empty or repeated are left out.
+func (rules tagRules) apply(ancestors []notion.Ancestor) []string {
+	entries := make([]tagEntry, len(ancestors))
+	for i := range ancestors {
+		entries[i] = tagEntry{name: ancestors[i].Title, parent: &ancestors[i]}
+	}
+	for _, rule := range rules {
+		entries = rule.apply(entries, ancestors)
+	}
+
+	var tags []string
+	for _, entry := range entries {
+		if entry.name != "" && !slices.Contains(tags, entry.name) {
+			tags = append(tags, entry.name)
+		}
+	}
+	return tags
+}
+
+// apply applies the rule to the tags
+func (r *tagRule) apply(entries []tagEntry, ancestors []notion.Ancestor) []tagEntry {
+	kept := make([]tagEntry, 0, len(entries))
+
+	switch {
+	case r.Add != nil:
+		kept = append(kept, entries...)
+		for _, ancestor := range ancestors {
+			if ancestor.Type != notion.ParentPage && matchesParent(ancestor, r.Add.Database) {
+				for _, tag := range r.Add.Tags {
+					kept = append(kept, tagEntry{name: tag})
+				}
+				break
+			}
+		}
+		return kept
+
+	case r.MaxDepth > 0:
+		depth := 0
+		for _, entry := range entries {
+			if entry.parent != nil {
+				depth++
+				if depth > r.MaxDepth {
+					continue
+				}
+			}
+			kept = append(kept, entry)
+		}
+		return kept
+	}
+
+	for _, entry := range entries {
+		if !r.appliesTo(entry) {
+			kept = append(kept, entry)
+			continue
+		}
+
+		switch {
+		case r.Rename != nil:
+			if entry.name == r.Rename.From {
+				entry.name = r.Rename.To
+			}
+		case r.Lowercase:
+			entry.name = strings.ToLower(entry.name)
+		case r.Replace != nil:
+			entry.name = r.pattern.ReplaceAllString(entry.name, r.Replace.With)
+		case r.Drop != "":
+			if r.pattern.MatchString(entry.name) {
+				continue
+			}
+		case r.Map != nil:
+			if entry.parent != nil && matchesParent(*entry.parent, r.Map.Parent) {
+				entry.name = r.Map.Tag
+			}
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// appliesTo reports whether the rule's Only restriction admits the tag
+func (r *tagRule) appliesTo(entry tagEntry) bool {
+	switch r.Only {
+	case config.TagParentPage:
+		return entry.parent != nil && entry.parent.Type == notion.ParentPage
+	case config.TagParentDatabase:
+		return entry.parent != nil && entry.parent.Type != notion.ParentPage
+	}
+	return true
+}
+
+// matchesParent reports whether an ancestor is the one a rule refers to by
+// ID or exact title
+func matchesParent(ancestor notion.Ancestor, idOrTitle string) bool {
+	if isNotionID(idOrTitle) {
+		return normalizeID(ancestor.ID) == normalizeID(idOrTitle)
+	}
+	return ancestor.Title == idOrTitle
+}
+
+// TagPreview is what the tag rules make of the parents of a page or a
+// database snapshot
+type TagPreview struct {
+	Title    string
+	ID       string
+	Snapshot bool     // a database migrated as a snapshot memo
+	Parents  []string // titles of the ancestors, outermost first
+	Tags     []string // as they appear in memos, without "#"
+	// Err is set if not all ancestors could be retrieved; Parents and Tags
+	// cover the ones that were
+	Err error
+}
+
+// PreviewTags yields the tags every page and standalone database snapshot
+// would be migrated with, without writing anything. With filterTitles only
+// the pages and databases with these titles are previewed.
+func (m *Migrator) PreviewTags(ctx context.Context, filterTitles []string) iter.Seq2[TagPreview, error] {
+	return func(yield func(TagPreview, error) bool) {
+		if err := m.resolveSnapshots(ctx); err != nil {
+			yield(TagPreview{}, err)
+			return
+		}
+
+		for _, snapshot := range m.standaloneSnapshots(filterTitles) {
+			preview := m.previewTags(ctx, snapshot.database.GetDatabaseTitle(), snapshot.database.ID, snapshot.database.Parent)
+			preview.Snapshot = true
+			if !yield(preview, nil) {
+				return
+			}
+		}
+
+		filter := newPageFilter(MigrateOptions{FilterTitles: filterTitles})
+		for page, err := range m.notionClient.SearchPagesIter(ctx, "") {
+			if err != nil {
+				yield(TagPreview{}, fmt.Errorf("failed to search pages: %w", err))
+				return
+			}
+			if !m.shouldMigrate(&page, filter) {
+				continue
+			}
+			if !yield(m.previewTags(ctx, page.GetPageTitle(), page.ID, page.Parent), nil) {
+				return
+			}
+		}
+	}
+}
+
+// previewTags resolves the ancestors of an object and applies the tag rules
+func (m *Migrator) previewTags(ctx context.Context, title, id string, parent notion.Parent) TagPreview {
+	ancestors, err := notion.ResolveAncestors(ctx, cachedRetriever{m}, parent)
+	preview := TagPreview{
+		Title: title,
+		ID:    id,
+		Tags:  notion.TagNames(m.tagRules.apply(ancestors)),
+		Err:   err,
+	}
+	for _, ancestor := range ancestors {
+		preview.Parents = append(preview.Parents, ancestor.Title)
+	}
+	return preview
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/OneManRepo/notion2memos/internal/config"
+	"github.com/OneManRepo/notion2memos/internal/notion"
+)
+
+func TestTagRules(t *testing.T) {
+	const journalID = "44444444-4444-4444-4444-444444444444"
+	ancestors := []notion.Ancestor{
+		{Type: notion.ParentPage, ID: "55555555-5555-5555-5555-555555555555", Title: "Projects"},
+		{Type: notion.ParentPage, ID: "66666666-6666-6666-6666-666666666666", Title: "08.12. Trip"},
+		{Type: notion.ParentDatabase, ID: journalID, Title: "Tagebuch"},
+		{Type: notion.ParentPage, ID: "22222222-2222-2222-2222-222222222222", Title: "Road Map"},
+	}
+
+	tests := []struct {
+		name  string
+		rules []config.TagRule
+		want  []string
+	}{
+		{"no rules", nil, []string{"Projects", "08.12. Trip", "Tagebuch", "Road Map"}},
+		{"default rules", config.DefaultTagRules, []string{"Projects", "tagebuch", "Road Map"}},
+		{"rename", []config.TagRule{{Rename: &config.TagRename{From: "Projects", To: "Work"}}},
+			[]string{"Work", "08.12. Trip", "Tagebuch", "Road Map"}},
+		{"lowercase database tags", []config.TagRule{{Lowercase: true, Only: config.TagParentDatabase}},
+			[]string{"Projects", "08.12. Trip", "tagebuch", "Road Map"}},
+		{"replace with group", []config.TagRule{{Replace: &config.TagReplace{Pattern: `^(\w+) (\w+)$`, With: "$1$2"}}},
+			[]string{"Projects", "08.12. Trip", "Tagebuch", "RoadMap"}},
+		{"drop page tags", []config.TagRule{{Drop: `^[A-Z]`, Only: config.TagParentPage}},
+			[]string{"08.12. Trip", "Tagebuch"}},
+		{"map by ID", []config.TagRule{{Map: &config.TagMap{Parent: journalID, Tag: "diary"}}},
+			[]string{"Projects", "08.12. Trip", "diary", "Road Map"}},
+		{"map by title", []config.TagRule{{Map: &config.TagMap{Parent: "Road Map", Tag: "roadmap"}}},
+			[]string{"Projects", "08.12. Trip", "Tagebuch", "roadmap"}},
+		{"max depth", []config.TagRule{{MaxDepth: 2}}, []string{"Projects", "08.12. Trip"}},
+		{"max depth after drop", []config.TagRule{{Drop: `^\d`}, {MaxDepth: 2}}, []string{"Projects", "Tagebuch"}},
+		{"add for database", []config.TagRule{{Add: &config.TagAdd{Database: "Tagebuch", Tags: []string{"journal", "private"}}}},
+			[]string{"Projects", "08.12. Trip", "Tagebuch", "Road Map", "journal", "private"}},
+		{"add for other database", []config.TagRule{{Add: &config.TagAdd{Database: "Projects", Tags: []string{"work"}}}},
+			[]string{"Projects", "08.12. Trip", "Tagebuch", "Road Map"}},
+		{"added tags kept by max depth", []config.TagRule{{Add: &config.TagAdd{Database: journalID, Tags: []string{"journal"}}}, {MaxDepth: 1}},
+			[]string{"Projects", "journal"}},
+		{"empty and repeated left out", []config.TagRule{{Replace: &config.TagReplace{Pattern: `.*Trip`, With: ""}}, {Rename: &config.TagRename{From: "Road Map", To: "Projects"}}},
+			[]string{"Projects", "Tagebuch"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := newTagRules(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules.apply(ancestors); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagRulesInvalidPattern(t *testing.T) {
+	if _, err := newTagRules([]config.TagRule{{Drop: "("}}); err == nil {
+		t.Error("invalid drop pattern accepted")
+	}
+	if _, err := newTagRules([]config.TagRule{{Replace: &config.TagReplace{Pattern: "[a-"}}}); err == nil {
+		t.Error("invalid replace pattern accepted")
+	}
+}
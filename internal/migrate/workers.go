@@ -0,0 +1,213 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/OneManRepo/notion2memos/internal/notion"
+	"github.com/schollz/progressbar/v3"
+)
+
+// pageOutcome is how a page that didn't fail ended
+type pageOutcome int
+
+const (
+	pageMigrated pageOutcome = iota
+	pageEmpty                // the page has no content, so no memo was written
+	pageSkipped              // skipped by the error policy with a recorded reason
+)
+
+// pageResult is a page handed to a worker and, once migrated, its outcome
+type pageResult struct {
+	seq       int // position in the search order
+	page      notion.Page
+	outcome   pageOutcome
+	err       error
+	abandoned bool // the migration was stopped while the page was being fetched
+	failed    bool // the page failed and the migration continues without it
+}
+
+// migratePages migrates the pages of source that pass the filter with
+// opts.Workers workers and returns how many were migrated. Block fetches, Markdown
+// conversion and Memos writes of different pages overlap; all Notion
+// requests share the client's rate limiter, so adding workers never exceeds
+// Notion's limit.
+//
+// Pages are marked processed in search order. The state is saved whenever
+// a page finishes, so the memos of a page that finished ahead of a slower
+// one are kept even if the run dies, and resuming reuses them.
+//
+// A failed page stops the migration unless opts.ContinueOnError is set; then
+// it is recorded as failed and the others go on, up to a fatal error or
+// maxConsecutiveFailures failures in a row.
+func (m *Migrator) migratePages(ctx context.Context, source pageSource, filter *pageFilter, opts MigrateOptions, bar *progressbar.ProgressBar) (int, error) {
+	workers := max(opts.Workers, 1)
+
+	// Failures cancel the pages still being fetched; pages whose memos are
+	// being written are finished either way
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Cancelling stops the fetches of pages that were never migrated, and
+	// by the time this returns no worker can pick them up anymore
+	defer m.dropBlockFetches()
+
+	jobs := make(chan pageResult)
+	var searchErr error
+	go func() {
+		defer close(jobs)
+		seq := 0
+		for page, err := range m.prefetchPages(runCtx, source, filter, prefetchDepth+workers-1) {
+			if err != nil {
+				if runCtx.Err() == nil {
+					searchErr = err
+					cancel()
+				}
+				return
+			}
+			select {
+			case jobs <- pageResult{seq: seq, page: page}:
+				seq++
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan pageResult)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for job := range jobs {
+				job.outcome, job.err = m.migratePageWithPolicy(runCtx, &job.page)
+				results <- job
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Every page handed to a worker comes back, so the buffer always drains
+	migrated := 0
+	order := newCommitOrder()
+	consecutiveFailures := 0
+	var abortErr, saveErr error
+	for result := range results {
+		switch {
+		case result.err == nil:
+			consecutiveFailures = 0
+		case runCtx.Err() != nil:
+			result.abandoned = true
+		case opts.ContinueOnError && !isFatal(result.err):
+			result.failed = true
+			consecutiveFailures++
+			if consecutiveFailures >= maxConsecutiveFailures {
+				abortErr = fmt.Errorf("stopped after %d pages in a row failed, the last one with: %w", consecutiveFailures, result.err)
+				cancel()
+			}
+		default:
+			abortErr = fmt.Errorf("failed to migrate page %s (%s): %w", result.page.GetPageTitle(), result.page.ID, result.err)
+			cancel()
+		}
+
+		for _, result := range order.add(result) {
+			if m.commitPage(result, workers > 1, bar) {
+				migrated++
+			}
+		}
+
+		if err := m.state.SaveState(); err != nil && saveErr == nil {
+			saveErr = fmt.Errorf("failed to save state: %w", err)
+			cancel()
+		}
+	}
+
+	switch {
+	case abortErr != nil:
+		return migrated, abortErr
+	case saveErr != nil:
+		return migrated, saveErr
+	case searchErr != nil:
+		return migrated, fmt.Errorf("failed to search pages: %w", searchErr)
+	}
+	return migrated, nil
+}
+
+// commitOrder puts the results of pages back in search order, holding back
+// the ones that finished ahead of an earlier page
+type commitOrder struct {
+	next     int
+	finished map[int]pageResult
+}
+
+func newCommitOrder() *commitOrder {
+	return &commitOrder{finished: make(map[int]pageResult)}
+}
+
+// add takes a finished page and returns the pages that are now due, in
+// search order
+func (o *commitOrder) add(result pageResult) []pageResult {
+	o.finished[result.seq] = result
+	var due []pageResult
+	for {
+		result, ok := o.finished[o.next]
+		if !ok {
+			return due
+		}
+		delete(o.finished, o.next)
+		o.next++
+		due = append(due, result)
+	}
+}
+
+// commitPage records the outcome of a page in the state and the progress
+// bar and reports whether it was migrated. With several workers the outcome
+// is logged with the page's position, since their log lines interleave.
+func (m *Migrator) commitPage(result pageResult, logOutcome bool, bar *progressbar.ProgressBar) bool {
+	title := result.page.GetPageTitle()
+	prefix := ""
+	if logOutcome {
+		prefix = fmt.Sprintf("[%d] ", result.seq+1)
+	}
+
+	switch {
+	case result.abandoned:
+		log.Printf("\n%sAbandoned page '%s': %v\n", prefix, title, result.err)
+		return false
+
+	case result.failed:
+		m.recordFailure(result.page.ID, title, result.err)
+		log.Printf("\n%sFailed page '%s': %v\n", prefix, title, result.err)
+		bar.Add(1)
+		return false
+
+	case result.err != nil:
+		// The failure stops the migration and is reported by migratePages
+		return false
+
+	case result.outcome == pageSkipped:
+		if logOutcome {
+			log.Printf("\n%sSkipped '%s'\n", prefix, title)
+		}
+		bar.Add(1)
+		return false
+
+	case result.outcome == pageEmpty:
+		// Recorded as processed, so that resuming doesn't fetch it again
+		m.state.MarkProcessed(result.page.ID)
+		m.empty++
+		log.Printf("\n%sSkipped empty page '%s'\n", prefix, title)
+		bar.Add(1)
+		return false
+	}
+
+	m.state.MarkProcessed(result.page.ID)
+	if logOutcome {
+		log.Printf("\n%sMigrated '%s'\n", prefix, title)
+	}
+	bar.Add(1)
+	return true
+}
@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommitOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		// finished is the order the pages finish in
+		finished []int
+		// due is what each finished page releases
+		due [][]int
+	}{
+		{"in order", []int{0, 1, 2}, [][]int{{0}, {1}, {2}}},
+		{"reversed", []int{2, 1, 0}, [][]int{nil, nil, {0, 1, 2}}},
+		{"pairs swapped", []int{1, 0, 3, 2}, [][]int{nil, {0, 1}, nil, {2, 3}}},
+		{"slow first page", []int{1, 2, 3, 0, 4}, [][]int{nil, nil, nil, {0, 1, 2, 3}, {4}}},
+		{"gap in the middle", []int{0, 2, 3, 1}, [][]int{{0}, nil, nil, {1, 2, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := newCommitOrder()
+			for i, seq := range tt.finished {
+				var due []int
+				for _, result := range order.add(pageResult{seq: seq}) {
+					due = append(due, result.seq)
+				}
+				if !reflect.DeepEqual(due, tt.due[i]) {
+					t.Errorf("page %d finished: got %v due, want %v", seq, due, tt.due[i])
+				}
+			}
+			if len(order.finished) != 0 {
+				t.Errorf("%d pages held back at the end", len(order.finished))
+			}
+		})
+	}
+}
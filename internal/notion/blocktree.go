@@ -0,0 +1,91 @@
+package notion
+
+import (
+	"context"
+	"sync"
+)
+
+// RetrieveBlockTree retrieves the blocks of a page or block together with
+// their nested children. The tree is fetched level by level, with the
+// children of the blocks on one level fetched concurrently by as many
+// workers as the client has request slots; every request still goes through
+// the rate limiter, and each block's children keep their Notion order.
+func (c *Client) RetrieveBlockTree(ctx context.Context, blockID string) ([]Block, error) {
+	blocks, err := c.RetrieveBlocks(ctx, blockID)
+	if err != nil {
+		return nil, err
+	}
+
+	for level := withNestedChildren(blocks); len(level) > 0; {
+		if err := c.retrieveChildren(ctx, level); err != nil {
+			return nil, err
+		}
+		var next []*Block
+		for _, parent := range level {
+			next = append(next, withNestedChildren(parent.Children)...)
+		}
+		level = next
+	}
+	return blocks, nil
+}
+
+// retrieveChildren fills in the children of the parents with a fixed number
+// of workers. The first failure stops the remaining fetches.
+func (c *Client) retrieveChildren(ctx context.Context, parents []*Block) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	queue := make(chan *Block)
+	var wg sync.WaitGroup
+	for range min(cap(c.slots), len(parents)) {
+		wg.Go(func() {
+			for parent := range queue {
+				children, err := c.RetrieveBlocks(ctx, parent.ID)
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				// Each parent is handed to one worker only
+				parent.Children = children
+			}
+		})
+	}
+
+enqueue:
+	for _, parent := range parents {
+		select {
+		case queue <- parent:
+		case <-ctx.Done():
+			break enqueue
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+// withNestedChildren returns the blocks whose children are still to be
+// fetched
+func withNestedChildren(blocks []Block) []*Block {
+	var parents []*Block
+	for i := range blocks {
+		if hasNestedChildren(&blocks[i]) {
+			parents = append(parents, &blocks[i])
+		}
+	}
+	return parents
+}
+
+// hasNestedChildren reports whether a block's children belong to its content.
+// Child pages and databases are separate objects that are migrated on their own.
+func hasNestedChildren(block *Block) bool {
+	if !block.HasChildren {
+		return false
+	}
+	switch block.Type {
+	case "child_page", "child_database":
+		return false
+	}
+	return true
+}
@@ -1,35 +1,80 @@
 package notion
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
 const (
-	notionAPIBase    = "https://api.notion.com/v1"
+	defaultAPIBase   = "https://api.notion.com/v1"
 	notionAPIVersion = "2025-09-03"
 	rateLimit        = 3 // 3 requests per second
+
+	// defaultMaxConcurrentRequests bounds the requests in flight at once. The
+	// rate limiter still paces them; concurrency only overlaps their latency.
+	defaultMaxConcurrentRequests = 4
 )
 
 // Client is a Notion API client
 type Client struct {
+	baseURL    string
 	token      string
 	httpClient *http.Client
 	limiter    *rate.Limiter
+	retry      RetryOptions
+	slots      chan struct{} // semaphore bounding concurrent requests
+
+	mu        sync.Mutex
+	successes int // successful requests since the limiter was last slowed down
+	retries   int // total number of retried requests
+}
+
+// ClientOptions configures a Notion API client
+type ClientOptions struct {
+	// BaseURL overrides the API endpoint, e.g. to use a local fake server
+	BaseURL string
+	// HTTPClient overrides the HTTP client used for requests
+	HTTPClient *http.Client
+	Retry      RetryOptions
+	// MaxConcurrentRequests bounds the requests in flight at once (default 4).
+	// All requests share one rate limiter regardless of this bound.
+	MaxConcurrentRequests int
 }
 
 // NewClient creates a new Notion API client
-func NewClient(token string) *Client {
+func NewClient(token string, opts ClientOptions) *Client {
+	baseURL := defaultAPIBase
+	if opts.BaseURL != "" {
+		baseURL = strings.TrimRight(opts.BaseURL, "/")
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	maxConcurrent := opts.MaxConcurrentRequests
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrentRequests
+	}
+
 	return &Client{
+		baseURL:    baseURL,
 		token:      token,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpClient,
 		limiter:    rate.NewLimiter(rate.Limit(rateLimit), 1),
+		retry:      opts.Retry.withDefaults(),
+		slots:      make(chan struct{}, maxConcurrent),
 	}
 }
 
@@ -43,20 +88,92 @@ type SearchResponse struct {
 
 // Page represents a Notion page
 type Page struct {
-	Object         string                 `json:"object"`
-	ID             string                 `json:"id"`
-	CreatedTime    string                 `json:"created_time"`
-	LastEditedTime string                 `json:"last_edited_time"`
-	Parent         map[string]interface{} `json:"parent"`
-	Properties     map[string]Property    `json:"properties"`
-	URL            string                 `json:"url"`
+	Object         string              `json:"object"`
+	ID             string              `json:"id"`
+	CreatedTime    string              `json:"created_time"`
+	LastEditedTime string              `json:"last_edited_time"`
+	CreatedBy      User                `json:"created_by"`
+	Parent         Parent              `json:"parent"`
+	Properties     map[string]Property `json:"properties"`
+	URL            string              `json:"url"`
 }
 
-// Property represents a page property
+// Property represents a page property. Only the field matching Type is set.
 type Property struct {
-	ID    string     `json:"id"`
-	Type  string     `json:"type"`
-	Title []RichText `json:"title,omitempty"`
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	Title          []RichText      `json:"title,omitempty"`
+	RichText       []RichText      `json:"rich_text,omitempty"`
+	Number         *float64        `json:"number,omitempty"`
+	Select         *SelectOption   `json:"select,omitempty"`
+	MultiSelect    []SelectOption  `json:"multi_select,omitempty"`
+	Status         *SelectOption   `json:"status,omitempty"`
+	Date           *DateValue      `json:"date,omitempty"`
+	Checkbox       bool            `json:"checkbox,omitempty"`
+	URL            *string         `json:"url,omitempty"`
+	Email          *string         `json:"email,omitempty"`
+	PhoneNumber    *string         `json:"phone_number,omitempty"`
+	People         []User          `json:"people,omitempty"`
+	Relation       []PageReference `json:"relation,omitempty"`
+	Formula        *FormulaValue   `json:"formula,omitempty"`
+	UniqueID       *UniqueIDValue  `json:"unique_id,omitempty"`
+	CreatedTime    string          `json:"created_time,omitempty"`
+	LastEditedTime string          `json:"last_edited_time,omitempty"`
+}
+
+// SelectOption represents a select, multi-select or status option
+type SelectOption struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// DateValue represents a date or date range
+type DateValue struct {
+	Start string  `json:"start"`
+	End   *string `json:"end"`
+}
+
+// PageReference references a page, e.g. in a relation property
+type PageReference struct {
+	ID string `json:"id"`
+}
+
+// FormulaValue represents the computed value of a formula property
+type FormulaValue struct {
+	Type    string     `json:"type"`
+	String  *string    `json:"string,omitempty"`
+	Number  *float64   `json:"number,omitempty"`
+	Boolean *bool      `json:"boolean,omitempty"`
+	Date    *DateValue `json:"date,omitempty"`
+}
+
+// UniqueIDValue represents the value of a unique ID property
+type UniqueIDValue struct {
+	Prefix *string `json:"prefix"`
+	Number int     `json:"number"`
+}
+
+// User represents a Notion user. References embedded in pages and people
+// properties usually only carry the ID; use RetrieveUser for the full object.
+type User struct {
+	Object string      `json:"object"`
+	ID     string      `json:"id"`
+	Type   string      `json:"type,omitempty"`
+	Name   string      `json:"name,omitempty"`
+	Person *PersonInfo `json:"person,omitempty"`
+}
+
+// PersonInfo holds the details of a user of type "person"
+type PersonInfo struct {
+	Email string `json:"email"`
+}
+
+// GetEmail returns the user's email address if it is known
+func (u *User) GetEmail() string {
+	if u.Person != nil {
+		return u.Person.Email
+	}
+	return ""
 }
 
 // RichText represents rich text content
@@ -105,6 +222,7 @@ type Block struct {
 	CreatedTime    string          `json:"created_time"`
 	LastEditedTime string          `json:"last_edited_time"`
 	HasChildren    bool            `json:"has_children"`
+	Parent         Parent          `json:"parent"`
 	Paragraph      *ParagraphBlock `json:"paragraph,omitempty"`
 	Heading1       *HeadingBlock   `json:"heading_1,omitempty"`
 	Heading2       *HeadingBlock   `json:"heading_2,omitempty"`
@@ -113,6 +231,10 @@ type Block struct {
 	NumberedList   *ListBlock      `json:"numbered_list_item,omitempty"`
 	ToDo           *ToDoBlock      `json:"to_do,omitempty"`
 	Code           *CodeBlock      `json:"code,omitempty"`
+	ChildDatabase  *ChildDatabase  `json:"child_database,omitempty"`
+
+	// Children are the nested blocks, filled in by RetrieveBlockTree
+	Children []Block `json:"children,omitempty"`
 }
 
 // ParagraphBlock represents a paragraph block
@@ -146,44 +268,122 @@ type CodeBlock struct {
 	Language string     `json:"language"`
 }
 
-// doRequest performs an HTTP request with rate limiting
-func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
-	// Wait for rate limiter
-	if err := c.limiter.Wait(req.Context()); err != nil {
-		return nil, fmt.Errorf("rate limiter error: %w", err)
-	}
+// ChildDatabase represents an inline database block. The block ID is the
+// database ID.
+type ChildDatabase struct {
+	Title string `json:"title"`
+}
 
+// doRequest performs an HTTP request with rate limiting. Throttled requests,
+// conflicts, server and transport errors are retried with backoff.
+func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
 	// Set headers
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Notion-Version", notionAPIVersion)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+	// Take a request slot; it is held across retries so that a throttled
+	// request doesn't let others pile up behind it
+	select {
+	case c.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, fmt.Errorf("request cancelled: %w", req.Context().Err())
 	}
+	defer func() { <-c.slots }()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
+	for attempt := 0; ; attempt++ {
+		// Wait for rate limiter
+		if err := c.limiter.Wait(req.Context()); err != nil {
+			return nil, fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		// Rewind the body of a retried request
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		var retryAfter time.Duration
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if req.Context().Err() != nil {
+				return nil, fmt.Errorf("request failed: %w", err)
+			}
+			err = fmt.Errorf("request failed: %w", err)
+		} else if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			c.recordSuccess()
+			return resp, nil
+		} else {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			err = parseAPIError(resp.StatusCode, body)
+			if !isRetryableStatus(resp.StatusCode) {
+				return nil, err
+			}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				c.throttle()
+			}
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+
+		if attempt >= c.retry.MaxRetries {
+			if attempt > 0 {
+				return nil, fmt.Errorf("%w (gave up after %d retries)", err, attempt)
+			}
+			return nil, err
+		}
+
+		delay := c.retry.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		c.recordRetry()
+		log.Printf("Notion API %s %s: %v; retrying in %s (retry %d/%d)\n",
+			req.Method, req.URL.Path, err, delay.Round(time.Millisecond), attempt+1, c.retry.MaxRetries)
 
-	return resp, nil
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, fmt.Errorf("request cancelled while waiting to retry: %w", err)
+		}
+	}
 }
 
 // SearchPages searches for pages matching the query
-func (c *Client) SearchPages(query string) ([]Page, error) {
-	var allPages []Page
-	var cursor *string
+func (c *Client) SearchPages(ctx context.Context, query string) ([]Page, error) {
+	return collect(c.SearchPagesIter(ctx, query))
+}
+
+// SearchPagesIter searches for pages matching the query and yields them as
+// they arrive, fetching the next batch only when the previous one is consumed
+func (c *Client) SearchPagesIter(ctx context.Context, query string) iter.Seq2[Page, error] {
+	return c.searchPages(ctx, query, nil)
+}
+
+// SearchEditedPagesIter yields all pages, most recently edited first.
+// Stopping at the first page edited before a point in time finds everything
+// edited since without listing the whole workspace.
+func (c *Client) SearchEditedPagesIter(ctx context.Context) iter.Seq2[Page, error] {
+	return c.searchPages(ctx, "", map[string]interface{}{
+		"direction": "descending",
+		"timestamp": "last_edited_time",
+	})
+}
 
-	for {
+// searchPages yields the pages matching the query in the given sort order
+// (Notion's relevance order if nil)
+func (c *Client) searchPages(ctx context.Context, query string, sort map[string]interface{}) iter.Seq2[Page, error] {
+	return paginate(func(cursor *string) ([]Page, *string, error) {
 		payload := map[string]interface{}{
 			"page_size": 100,
 		}
 		if query != "" {
 			payload["query"] = query
 		}
+		if sort != nil {
+			payload["sort"] = sort
+		}
 		if cursor != nil {
 			payload["start_cursor"] = *cursor
 		}
@@ -194,42 +394,17 @@ func (c *Client) SearchPages(query string) ([]Page, error) {
 			"value":    "page",
 		}
 
-		body, err := json.Marshal(payload)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request: %w", err)
-		}
-
-		req, err := http.NewRequest("POST", notionAPIBase+"/search", bytes.NewReader(body))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		resp, err := c.doRequest(req)
-		if err != nil {
-			return nil, err
-		}
-
 		var searchResp SearchResponse
-		if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to decode response: %w", err)
+		if err := c.postList(ctx, "/search", payload, &searchResp); err != nil {
+			return nil, nil, err
 		}
-		resp.Body.Close()
-
-		allPages = append(allPages, searchResp.Results...)
-
-		if !searchResp.HasMore {
-			break
-		}
-		cursor = searchResp.NextCursor
-	}
-
-	return allPages, nil
+		return searchResp.Results, nextCursor(searchResp.HasMore, searchResp.NextCursor), nil
+	})
 }
 
 // RetrievePage retrieves a page by ID
-func (c *Client) RetrievePage(pageID string) (*Page, error) {
-	req, err := http.NewRequest("GET", notionAPIBase+"/pages/"+pageID, nil)
+func (c *Client) RetrievePage(ctx context.Context, pageID string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/pages/"+pageID, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -249,42 +424,77 @@ func (c *Client) RetrievePage(pageID string) (*Page, error) {
 }
 
 // RetrieveBlocks retrieves all blocks for a page or block
-func (c *Client) RetrieveBlocks(blockID string) ([]Block, error) {
-	var allBlocks []Block
-	var cursor *string
+func (c *Client) RetrieveBlocks(ctx context.Context, blockID string) ([]Block, error) {
+	return collect(c.RetrieveBlocksIter(ctx, blockID))
+}
 
-	for {
-		url := fmt.Sprintf("%s/blocks/%s/children?page_size=100", notionAPIBase, blockID)
+// RetrieveBlocksIter yields the blocks of a page or block as they arrive
+func (c *Client) RetrieveBlocksIter(ctx context.Context, blockID string) iter.Seq2[Block, error] {
+	return paginate(func(cursor *string) ([]Block, *string, error) {
+		url := fmt.Sprintf("%s/blocks/%s/children?page_size=100", c.baseURL, blockID)
 		if cursor != nil {
 			url += "&start_cursor=" + *cursor
 		}
 
-		req, err := http.NewRequest("GET", url, nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
+			return nil, nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
 		resp, err := c.doRequest(req)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		defer resp.Body.Close()
 
 		var blockResp BlockResponse
 		if err := json.NewDecoder(resp.Body).Decode(&blockResp); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to decode response: %w", err)
+			return nil, nil, fmt.Errorf("failed to decode response: %w", err)
 		}
-		resp.Body.Close()
+		return blockResp.Results, nextCursor(blockResp.HasMore, blockResp.NextCursor), nil
+	})
+}
+
+// RetrieveBlock retrieves a single block by ID
+func (c *Client) RetrieveBlock(ctx context.Context, blockID string) (*Block, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/blocks/"+blockID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
 
-		allBlocks = append(allBlocks, blockResp.Results...)
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-		if !blockResp.HasMore {
-			break
-		}
-		cursor = blockResp.NextCursor
+	var block Block
+	if err := json.NewDecoder(resp.Body).Decode(&block); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &block, nil
+}
+
+// RetrieveUser retrieves a user by ID
+func (c *Client) RetrieveUser(ctx context.Context, userID string) (*User, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/users/"+userID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return allBlocks, nil
+	return &user, nil
 }
 
 // GetPageTitle extracts the title from a page
@@ -297,28 +507,55 @@ func (p *Page) GetPageTitle() string {
 	return "Untitled"
 }
 
+// GetPeople returns the users of the named people property. The special
+// name "created_by" returns the page's creator.
+func (p *Page) GetPeople(property string) []User {
+	if property == "" || property == "created_by" {
+		if p.CreatedBy.ID == "" {
+			return nil
+		}
+		return []User{p.CreatedBy}
+	}
+	if prop, ok := p.Properties[property]; ok {
+		switch prop.Type {
+		case "people":
+			return prop.People
+		case "created_by":
+			return []User{p.CreatedBy}
+		}
+	}
+	return nil
+}
+
 // GetParentPageID extracts the parent page ID if the parent is a page
 func (p *Page) GetParentPageID() string {
-	if pageID, ok := p.Parent["page_id"].(string); ok {
-		return pageID
+	if p.Parent.Type == ParentPage {
+		return p.Parent.PageID
 	}
 	return ""
 }
 
-// GetParentDatabaseID extracts the parent database ID if the parent is a database
+// GetParentDatabaseID extracts the parent database ID if the parent is a
+// database or one of its data sources
 func (p *Page) GetParentDatabaseID() string {
-	if dbID, ok := p.Parent["database_id"].(string); ok {
-		return dbID
-	}
-	return ""
+	return p.Parent.DatabaseID
 }
 
 // Database represents a Notion database
 type Database struct {
-	Object string                 `json:"object"`
-	ID     string                 `json:"id"`
-	Title  []RichText             `json:"title"`
-	Parent map[string]interface{} `json:"parent"`
+	Object         string          `json:"object"`
+	ID             string          `json:"id"`
+	CreatedTime    string          `json:"created_time"`
+	LastEditedTime string          `json:"last_edited_time"`
+	Title          []RichText      `json:"title"`
+	Parent         Parent          `json:"parent"`
+	DataSources    []DataSourceRef `json:"data_sources"`
+}
+
+// DataSourceRef references a data source of a database
+type DataSourceRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 // GetDatabaseTitle extracts the title from a database
@@ -330,8 +567,8 @@ func (d *Database) GetDatabaseTitle() string {
 }
 
 // RetrieveDatabase retrieves a database by ID
-func (c *Client) RetrieveDatabase(databaseID string) (*Database, error) {
-	req, err := http.NewRequest("GET", notionAPIBase+"/databases/"+databaseID, nil)
+func (c *Client) RetrieveDatabase(ctx context.Context, databaseID string) (*Database, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/databases/"+databaseID, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -349,33 +586,3 @@ func (c *Client) RetrieveDatabase(databaseID string) (*Database, error) {
 
 	return &database, nil
 }
-
-// GetParentTags retrieves all parent page titles as tags
-func (c *Client) GetParentTags(page *Page) ([]string, error) {
-	var tags []string
-
-	// Check if parent is a database
-	if dbID := page.GetParentDatabaseID(); dbID != "" {
-		database, err := c.RetrieveDatabase(dbID)
-		if err == nil {
-			tags = append(tags, database.GetDatabaseTitle())
-		}
-		// Note: We don't continue up the chain for databases as they might have page parents
-		// which we could add if needed
-	}
-
-	// Walk up the page parent chain (max 10 levels to prevent infinite loops)
-	currentPageID := page.GetParentPageID()
-	for i := 0; i < 10 && currentPageID != ""; i++ {
-		parentPage, err := c.RetrievePage(currentPageID)
-		if err != nil {
-			// If we can't retrieve the parent, just return what we have
-			break
-		}
-
-		tags = append([]string{parentPage.GetPageTitle()}, tags...) // Prepend to maintain hierarchy
-		currentPageID = parentPage.GetParentPageID()
-	}
-
-	return tags, nil
-}
@@ -0,0 +1,137 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// DataSource represents a Notion data source (the table behind a database)
+type DataSource struct {
+	Object         string                    `json:"object"`
+	ID             string                    `json:"id"`
+	LastEditedTime string                    `json:"last_edited_time"`
+	Title          []RichText                `json:"title"`
+	Parent         Parent                    `json:"parent"`
+	Properties     map[string]PropertySchema `json:"properties"`
+}
+
+// PropertySchema describes a property (column) of a data source
+type PropertySchema struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// QueryResponse represents the response from querying a data source
+type QueryResponse struct {
+	Object     string  `json:"object"`
+	Results    []Page  `json:"results"`
+	NextCursor *string `json:"next_cursor"`
+	HasMore    bool    `json:"has_more"`
+}
+
+// dataSourceSearchResponse represents a search response filtered to data sources
+type dataSourceSearchResponse struct {
+	Results    []DataSource `json:"results"`
+	NextCursor *string      `json:"next_cursor"`
+	HasMore    bool         `json:"has_more"`
+}
+
+// GetDataSourceTitle extracts the title from a data source
+func (d *DataSource) GetDataSourceTitle() string {
+	if len(d.Title) > 0 {
+		return d.Title[0].PlainText
+	}
+	return "Untitled Data Source"
+}
+
+// GetParentDatabaseID extracts the ID of the database the data source belongs to
+func (d *DataSource) GetParentDatabaseID() string {
+	return d.Parent.DatabaseID
+}
+
+// PropertyNames returns the property names with the title property first and
+// the rest in alphabetical order
+func (d *DataSource) PropertyNames() []string {
+	var title string
+	var names []string
+	for name, schema := range d.Properties {
+		if schema.Type == "title" {
+			title = name
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if title != "" {
+		names = append([]string{title}, names...)
+	}
+	return names
+}
+
+// RetrieveDataSource retrieves a data source by ID
+func (c *Client) RetrieveDataSource(ctx context.Context, dataSourceID string) (*DataSource, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/data_sources/"+dataSourceID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var dataSource DataSource
+	if err := json.NewDecoder(resp.Body).Decode(&dataSource); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &dataSource, nil
+}
+
+// QueryDataSource retrieves all rows (pages) of a data source
+func (c *Client) QueryDataSource(ctx context.Context, dataSourceID string) ([]Page, error) {
+	return collect(paginate(func(cursor *string) ([]Page, *string, error) {
+		payload := map[string]interface{}{
+			"page_size": 100,
+		}
+		if cursor != nil {
+			payload["start_cursor"] = *cursor
+		}
+
+		var queryResp QueryResponse
+		if err := c.postList(ctx, "/data_sources/"+dataSourceID+"/query", payload, &queryResp); err != nil {
+			return nil, nil, err
+		}
+		return queryResp.Results, nextCursor(queryResp.HasMore, queryResp.NextCursor), nil
+	}))
+}
+
+// SearchDataSources searches for data sources matching the query
+func (c *Client) SearchDataSources(ctx context.Context, query string) ([]DataSource, error) {
+	return collect(paginate(func(cursor *string) ([]DataSource, *string, error) {
+		payload := map[string]interface{}{
+			"page_size": 100,
+			"filter": map[string]interface{}{
+				"property": "object",
+				"value":    "data_source",
+			},
+		}
+		if query != "" {
+			payload["query"] = query
+		}
+		if cursor != nil {
+			payload["start_cursor"] = *cursor
+		}
+
+		var searchResp dataSourceSearchResponse
+		if err := c.postList(ctx, "/search", payload, &searchResp); err != nil {
+			return nil, nil, err
+		}
+		return searchResp.Results, nextCursor(searchResp.HasMore, searchResp.NextCursor), nil
+	}))
+}
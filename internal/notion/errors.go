@@ -0,0 +1,104 @@
+package notion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors matched by APIError through errors.Is
+var (
+	ErrUnauthorized       = errors.New("notion: unauthorized")
+	ErrRestrictedResource = errors.New("notion: restricted resource")
+	ErrObjectNotFound     = errors.New("notion: object not found")
+	ErrRateLimited        = errors.New("notion: rate limited")
+	ErrValidation         = errors.New("notion: validation error")
+	ErrConflict           = errors.New("notion: conflict")
+)
+
+// APIError is an error response from the Notion API
+type APIError struct {
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API request failed with status %d", e.Status)
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " [request " + e.RequestID + "]"
+	}
+	return msg
+}
+
+// Is matches the sentinel error of the error code, so callers can use
+// errors.Is(err, notion.ErrObjectNotFound)
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.Code == "unauthorized"
+	case ErrRestrictedResource:
+		return e.Code == "restricted_resource"
+	case ErrObjectNotFound:
+		return e.Code == "object_not_found"
+	case ErrRateLimited:
+		return e.Code == "rate_limited"
+	case ErrValidation:
+		return e.Code == "validation_error" || e.Code == "invalid_json" ||
+			e.Code == "invalid_request_url" || e.Code == "invalid_request" ||
+			e.Code == "missing_version"
+	case ErrConflict:
+		return e.Code == "conflict_error"
+	}
+	return false
+}
+
+// parseAPIError builds an APIError from an error response body. Bodies that
+// aren't Notion error JSON (e.g. from a proxy) get a code derived from the status.
+func parseAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{Status: status}
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Code == "" {
+		apiErr = &APIError{
+			Status:  status,
+			Code:    codeForStatus(status),
+			Message: string(body),
+		}
+	}
+	apiErr.Status = status
+	return apiErr
+}
+
+// codeForStatus maps an HTTP status to the Notion error code it usually carries
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "validation_error"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "restricted_resource"
+	case http.StatusNotFound:
+		return "object_not_found"
+	case http.StatusConflict:
+		return "conflict_error"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "service_unavailable"
+	case http.StatusGatewayTimeout:
+		return "gateway_timeout"
+	}
+	if status >= 500 {
+		return "internal_server_error"
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package notion
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseAPIError(t *testing.T) {
+	sentinels := []error{ErrUnauthorized, ErrRestrictedResource, ErrObjectNotFound, ErrRateLimited, ErrValidation, ErrConflict}
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		code   string
+		// is is the sentinel the error matches, nil for none
+		is error
+	}{
+		{"not found", 404, `{"object":"error","status":404,"code":"object_not_found","message":"Could not find page."}`, "object_not_found", ErrObjectNotFound},
+		{"restricted", 403, `{"object":"error","status":403,"code":"restricted_resource","message":"No access."}`, "restricted_resource", ErrRestrictedResource},
+		{"unauthorized", 401, `{"object":"error","status":401,"code":"unauthorized","message":"API token is invalid."}`, "unauthorized", ErrUnauthorized},
+		{"rate limited", 429, `{"object":"error","status":429,"code":"rate_limited","message":"Slow down."}`, "rate_limited", ErrRateLimited},
+		{"validation", 400, `{"object":"error","status":400,"code":"validation_error","message":"body.filter is invalid."}`, "validation_error", ErrValidation},
+		{"invalid json", 400, `{"object":"error","status":400,"code":"invalid_json","message":"Body is not JSON."}`, "invalid_json", ErrValidation},
+		{"missing version", 400, `{"object":"error","status":400,"code":"missing_version","message":"Notion-Version header missing."}`, "missing_version", ErrValidation},
+		{"conflict", 409, `{"object":"error","status":409,"code":"conflict_error","message":"Conflict."}`, "conflict_error", ErrConflict},
+		{"server error", 500, `{"object":"error","status":500,"code":"internal_server_error","message":"Oops."}`, "internal_server_error", nil},
+		{"proxy page", 502, "<html>Bad Gateway</html>", "internal_server_error", nil},
+		{"proxy 429", 429, "Too Many Requests", "rate_limited", ErrRateLimited},
+		{"proxy 404", 404, "", "object_not_found", ErrObjectNotFound},
+		{"unavailable", 503, "", "service_unavailable", nil},
+		{"JSON without code", 403, `{"message":"Forbidden"}`, "restricted_resource", ErrRestrictedResource},
+		{"unknown status", 418, "teapot", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := parseAPIError(tt.status, []byte(tt.body))
+			if apiErr.Status != tt.status || apiErr.Code != tt.code {
+				t.Errorf("got status %d code %q, want %d %q", apiErr.Status, apiErr.Code, tt.status, tt.code)
+			}
+
+			err := fmt.Errorf("failed to retrieve page: %w", apiErr)
+			for _, sentinel := range sentinels {
+				if got, want := errors.Is(err, sentinel), sentinel == tt.is; got != want {
+					t.Errorf("errors.Is(%v) = %v, want %v", sentinel, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+)
+
+// Parent types
+const (
+	ParentPage       = "page_id"
+	ParentDatabase   = "database_id"
+	ParentDataSource = "data_source_id"
+	ParentBlock      = "block_id"
+	ParentWorkspace  = "workspace"
+)
+
+// maxHierarchyDepth bounds the walk up the hierarchy to prevent infinite
+// loops. Blocks count as levels, so it is higher than the nesting of pages.
+const maxHierarchyDepth = 25
+
+// Parent identifies the parent of a page, database, data source or block.
+// Rows of a data source carry both DataSourceID and DatabaseID.
+type Parent struct {
+	Type         string `json:"type"`
+	PageID       string `json:"page_id,omitempty"`
+	DatabaseID   string `json:"database_id,omitempty"`
+	DataSourceID string `json:"data_source_id,omitempty"`
+	BlockID      string `json:"block_id,omitempty"`
+	Workspace    bool   `json:"workspace,omitempty"`
+}
+
+// Ancestor is a page, database or data source above an object in the hierarchy
+type Ancestor struct {
+	Type  string // ParentPage, ParentDatabase or ParentDataSource
+	ID    string
+	Title string
+}
+
+// ObjectRetriever retrieves the objects that make up the hierarchy.
+// Client implements it; callers may wrap it with caching.
+type ObjectRetriever interface {
+	RetrievePage(ctx context.Context, pageID string) (*Page, error)
+	RetrieveDatabase(ctx context.Context, databaseID string) (*Database, error)
+	RetrieveDataSource(ctx context.Context, dataSourceID string) (*DataSource, error)
+	RetrieveBlock(ctx context.Context, blockID string) (*Block, error)
+}
+
+// ResolveAncestors walks up the hierarchy starting at parent and returns the
+// ancestors, outermost first. Block parents (columns, toggles, ...) are walked
+// through without becoming ancestors themselves. A data source is only listed
+// separately if its title differs from its database's. If an object can't be
+// retrieved, the ancestors found so far are returned along with the error.
+func ResolveAncestors(ctx context.Context, r ObjectRetriever, parent Parent) ([]Ancestor, error) {
+	var ancestors []Ancestor
+	prepend := func(a Ancestor) {
+		ancestors = append([]Ancestor{a}, ancestors...)
+	}
+
+	current := parent
+	for depth := 0; depth < maxHierarchyDepth; depth++ {
+		switch current.Type {
+		case ParentPage:
+			page, err := r.RetrievePage(ctx, current.PageID)
+			if err != nil {
+				return ancestors, fmt.Errorf("failed to retrieve parent page %s: %w", current.PageID, err)
+			}
+			prepend(Ancestor{Type: ParentPage, ID: page.ID, Title: page.GetPageTitle()})
+			current = page.Parent
+
+		case ParentDataSource:
+			dataSource, err := r.RetrieveDataSource(ctx, current.DataSourceID)
+			if err != nil {
+				return ancestors, fmt.Errorf("failed to retrieve parent data source %s: %w", current.DataSourceID, err)
+			}
+			prepend(Ancestor{Type: ParentDataSource, ID: dataSource.ID, Title: dataSource.GetDataSourceTitle()})
+			// The data source's own parent is its database; fall back to the
+			// database ID carried by the child's parent reference
+			databaseID := current.DatabaseID
+			current = dataSource.Parent
+			if current.Type == "" && databaseID != "" {
+				current = Parent{Type: ParentDatabase, DatabaseID: databaseID}
+			}
+
+		case ParentDatabase:
+			database, err := r.RetrieveDatabase(ctx, current.DatabaseID)
+			if err != nil {
+				return ancestors, fmt.Errorf("failed to retrieve parent database %s: %w", current.DatabaseID, err)
+			}
+			title := database.GetDatabaseTitle()
+			// A data source named like its database adds nothing
+			if len(ancestors) > 0 && ancestors[0].Type == ParentDataSource && ancestors[0].Title == title {
+				ancestors = ancestors[1:]
+			}
+			prepend(Ancestor{Type: ParentDatabase, ID: database.ID, Title: title})
+			current = database.Parent
+
+		case ParentBlock:
+			block, err := r.RetrieveBlock(ctx, current.BlockID)
+			if err != nil {
+				return ancestors, fmt.Errorf("failed to retrieve parent block %s: %w", current.BlockID, err)
+			}
+			current = block.Parent
+
+		default:
+			// Workspace (or unknown) parent: the top of the hierarchy
+			return ancestors, nil
+		}
+	}
+
+	return ancestors, nil
+}
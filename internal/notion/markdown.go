@@ -6,8 +6,19 @@ import (
 	"time"
 )
 
+// MarkdownOptions customizes the conversion of blocks to Markdown
+type MarkdownOptions struct {
+	// ChildDatabases holds pre-rendered Markdown for inline databases, keyed
+	// by block ID. Inline databases without an entry are left out.
+	ChildDatabases map[string]string
+	// NoTitleTags leaves out the tag line under the title
+	NoTitleTags bool
+	// EndTags appends the tag line to the end of the memo
+	EndTags bool
+}
+
 // BlocksToMarkdown converts Notion blocks to Markdown format
-func BlocksToMarkdown(blocks []Block, createdTime, pageTitle string, tags []string) (string, error) {
+func BlocksToMarkdown(blocks []Block, createdTime, pageTitle string, tags []string, opts MarkdownOptions) (string, error) {
 	var md strings.Builder
 
 	// Add page title as H1
@@ -16,11 +27,8 @@ func BlocksToMarkdown(blocks []Block, createdTime, pageTitle string, tags []stri
 	}
 
 	// Add tags if present
-	if len(tags) > 0 {
-		for _, tag := range tags {
-			md.WriteString("#" + sanitizeTag(tag) + " ")
-		}
-		md.WriteString("\n\n")
+	if !opts.NoTitleTags {
+		writeTags(&md, tags)
 	}
 
 	// Add creation timestamp as metadata comment
@@ -31,15 +39,87 @@ func BlocksToMarkdown(blocks []Block, createdTime, pageTitle string, tags []stri
 		}
 	}
 
+	writeBlocks(&md, blocks, "", opts)
+
+	markdown := strings.TrimSpace(md.String())
+	if opts.EndTags && len(tags) > 0 {
+		markdown += "\n\n" + TagLine(tags)
+	}
+	return markdown, nil
+}
+
+// writeBlocks writes blocks and their nested children, prefixing every line
+// with indent
+func writeBlocks(md *strings.Builder, blocks []Block, indent string, opts MarkdownOptions) {
 	for _, block := range blocks {
 		blockMd := blockToMarkdown(&block)
+		if block.Type == "child_database" {
+			blockMd = opts.ChildDatabases[block.ID]
+		}
 		if blockMd != "" {
-			md.WriteString(blockMd)
+			md.WriteString(indentLines(blockMd, indent))
 			md.WriteString("\n")
 		}
+		if len(block.Children) > 0 {
+			writeBlocks(md, block.Children, indent+childIndent(&block), opts)
+		}
 	}
+}
 
-	return strings.TrimSpace(md.String()), nil
+// childIndent returns the indentation of a block's children: list items nest
+// their children under the item text, other blocks (columns, toggles, ...)
+// render their children at their own level
+func childIndent(block *Block) string {
+	switch block.Type {
+	case "bulleted_list_item", "to_do":
+		return "  "
+	case "numbered_list_item":
+		return "   "
+	}
+	return ""
+}
+
+// indentLines prefixes every non-empty line of text with indent
+func indentLines(text, indent string) string {
+	if indent == "" {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeTags writes the tag line followed by a blank line
+func writeTags(md *strings.Builder, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	for _, tag := range tags {
+		md.WriteString("#" + sanitizeTag(tag) + " ")
+	}
+	md.WriteString("\n\n")
+}
+
+// TagLine returns the tags as a line of "#tag" tokens, empty without tags
+func TagLine(tags []string) string {
+	tokens := TagNames(tags)
+	for i, tag := range tokens {
+		tokens[i] = "#" + tag
+	}
+	return strings.Join(tokens, " ")
+}
+
+// TagNames returns the tags as they appear in memos, without the "#"
+func TagNames(tags []string) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = sanitizeTag(tag)
+	}
+	return names
 }
 
 // blockToMarkdown converts a single block to Markdown
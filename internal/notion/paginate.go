@@ -0,0 +1,85 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+)
+
+// fetchPage fetches one batch of a paginated endpoint. It returns the results
+// and the cursor of the next batch, or nil if there is none.
+type fetchPage[T any] func(cursor *string) ([]T, *string, error)
+
+// paginate turns a paginated endpoint into an iterator. Batches are fetched
+// lazily; iteration stops after the first error.
+func paginate[T any](fetch fetchPage[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var cursor *string
+		for {
+			results, next, err := fetch(cursor)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, result := range results {
+				if !yield(result, nil) {
+					return
+				}
+			}
+
+			if next == nil {
+				return
+			}
+			cursor = next
+		}
+	}
+}
+
+// collect gathers all results of an iterator into a slice
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var all []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, item)
+	}
+	return all, nil
+}
+
+// nextCursor returns the cursor of the next batch, or nil at the end
+func nextCursor(hasMore bool, cursor *string) *string {
+	if !hasMore || cursor == nil || *cursor == "" {
+		return nil
+	}
+	return cursor
+}
+
+// postList POSTs a JSON payload to a list endpoint and decodes the response
+func (c *Client) postList(ctx context.Context, path string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package notion
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+
+	// After being throttled the request rate is halved, down to minRateLimit,
+	// and raised again by a quarter after every recoverAfter successes
+	minRateLimit = 0.5
+	recoverAfter = 20
+)
+
+// RetryOptions configures how failed requests are retried
+type RetryOptions struct {
+	// MaxRetries is the number of retries per request (0 disables retries)
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// withDefaults fills in unset backoff durations
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = defaultInitialBackoff
+	}
+	if o.MaxBackoff < o.InitialBackoff {
+		o.MaxBackoff = max(defaultMaxBackoff, o.InitialBackoff)
+	}
+	return o
+}
+
+// backoff returns the delay before the given retry: exponential growth
+// capped at MaxBackoff, with "equal jitter" to spread out concurrent clients
+func (o RetryOptions) backoff(attempt int) time.Duration {
+	delay := o.InitialBackoff
+	for i := 0; i < attempt && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, o.MaxBackoff)
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// isRetryableStatus reports whether a request with this status may succeed
+// when retried
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests ||
+		status == http.StatusConflict ||
+		status >= 500
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// sleepContext waits for the given duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttle slows down the rate limiter after Notion returned 429
+func (c *Client) throttle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.successes = 0
+	limit := c.limiter.Limit() / 2
+	if limit < minRateLimit {
+		limit = minRateLimit
+	}
+	if limit != c.limiter.Limit() {
+		log.Printf("Notion API rate limited; slowing down to %.2f requests/sec\n", float64(limit))
+		c.limiter.SetLimit(limit)
+	}
+}
+
+// recordSuccess speeds the rate limiter back up after a run of successful requests
+func (c *Client) recordSuccess() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limiter.Limit() >= rate.Limit(rateLimit) {
+		return
+	}
+
+	c.successes++
+	if c.successes >= recoverAfter {
+		c.successes = 0
+		c.limiter.SetLimit(min(c.limiter.Limit()*1.25, rate.Limit(rateLimit)))
+	}
+}
+
+// recordRetry counts a retried request
+func (c *Client) recordRetry() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retries++
+}
+
+// Retries returns the number of requests retried so far
+func (c *Client) Retries() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.retries
+}
@@ -0,0 +1,269 @@
+package notion
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DatabaseToMarkdown renders the rows of a database as a GFM table memo.
+// If the memo would exceed maxLength it is split by rows into several parts,
+// each repeating the title, tags (placed as opts says) and table header. A
+// row that doesn't fit into a part on its own has its longest cells cut.
+func DatabaseToMarkdown(title string, tags []string, columns []string, rows []Page, maxLength int, opts MarkdownOptions) []string {
+	header := tableHeader(columns)
+	var tagLine strings.Builder
+	if !opts.NoTitleTags {
+		writeTags(&tagLine, tags)
+	}
+	endTags := ""
+	if opts.EndTags && len(tags) > 0 {
+		endTags = "\n\n" + TagLine(tags)
+	}
+
+	// Reserve room for the "# Title (nn/nn)" line of split parts
+	prefixLength := len("# "+title+" (99/99)\n\n") + tagLine.Len() + len(header) + len(endTags)
+	budget := maxLength - prefixLength
+
+	var chunks []string
+	var chunk strings.Builder
+	for _, row := range rows {
+		cells := tableCells(columns, &row)
+		line := formatRow(cells)
+		if len(line) > budget {
+			fitCells(cells, budget-(len(line)-totalLength(cells)))
+			line = formatRow(cells)
+		}
+		if chunk.Len() > 0 && chunk.Len()+len(line) > budget {
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+		}
+		chunk.WriteString(line)
+	}
+	if chunk.Len() > 0 || len(chunks) == 0 {
+		chunks = append(chunks, chunk.String())
+	}
+
+	parts := make([]string, len(chunks))
+	for i, rowsMarkdown := range chunks {
+		partTitle := title
+		if len(chunks) > 1 {
+			partTitle = fmt.Sprintf("%s (%d/%d)", title, i+1, len(chunks))
+		}
+		parts[i] = strings.TrimSpace("# "+partTitle+"\n\n"+tagLine.String()+header+rowsMarkdown) + endTags
+	}
+	return parts
+}
+
+// RenderTable renders database rows as a GFM table
+func RenderTable(columns []string, rows []Page) string {
+	var table strings.Builder
+	table.WriteString(tableHeader(columns))
+	for _, row := range rows {
+		table.WriteString(formatRow(tableCells(columns, &row)))
+	}
+	return table.String()
+}
+
+// tableHeader renders the header and delimiter rows of a table
+func tableHeader(columns []string) string {
+	cells := make([]string, len(columns))
+	delimiters := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = escapeTableCell(column)
+		delimiters[i] = "---"
+	}
+	return "| " + strings.Join(cells, " | ") + " |\n| " + strings.Join(delimiters, " | ") + " |\n"
+}
+
+// tableCells returns the escaped cells of one database row
+func tableCells(columns []string, row *Page) []string {
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		if prop, ok := row.Properties[column]; ok {
+			cells[i] = escapeTableCell(prop.PlainText())
+		}
+	}
+	return cells
+}
+
+// formatRow renders the cells of a table row
+func formatRow(cells []string) string {
+	return "| " + strings.Join(cells, " | ") + " |\n"
+}
+
+// totalLength returns the combined length of the cells in bytes
+func totalLength(cells []string) int {
+	total := 0
+	for _, cell := range cells {
+		total += len(cell)
+	}
+	return total
+}
+
+// fitCells cuts the longest cells down to a common length, so that all
+// cells together take at most available bytes. Shorter cells are kept.
+func fitCells(cells []string, available int) {
+	lengths := make([]int, len(cells))
+	for i, cell := range cells {
+		lengths[i] = len(cell)
+	}
+	slices.Sort(lengths)
+
+	// The longest length every cell may keep: cells shorter than it keep
+	// theirs, the remaining room is shared by the longer ones
+	limit := 0
+	remaining := max(available, 0)
+	for i, length := range lengths {
+		if longer := len(lengths) - i; length*longer > remaining {
+			limit = remaining / longer
+			break
+		}
+		remaining -= length
+	}
+
+	for i, cell := range cells {
+		if len(cell) > limit {
+			cells[i] = truncateCell(cell, limit)
+		}
+	}
+}
+
+// truncateCell shortens an escaped cell to at most limit bytes, ending it
+// with "…". It cuts between runes and never through an escape.
+func truncateCell(cell string, limit int) string {
+	const ellipsis = "…"
+	if limit < len(ellipsis) {
+		return ""
+	}
+	cut := limit - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(cell[cut]) {
+		cut--
+	}
+	cell = cell[:cut]
+	if i := strings.LastIndex(cell, "<"); i >= 0 && strings.HasPrefix("<br>", cell[i:]) {
+		cell = cell[:i]
+	}
+	cell = strings.TrimSuffix(cell, "\\")
+	return cell + ellipsis
+}
+
+// escapeTableCell keeps a value on one line and inside its cell
+func escapeTableCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	value = strings.ReplaceAll(value, "\r\n", "<br>")
+	return strings.ReplaceAll(value, "\n", "<br>")
+}
+
+// PlainText renders a property value as text
+func (p *Property) PlainText() string {
+	switch p.Type {
+	case "title":
+		return richTextToMarkdown(p.Title)
+	case "rich_text":
+		return richTextToMarkdown(p.RichText)
+	case "number":
+		if p.Number != nil {
+			return strconv.FormatFloat(*p.Number, 'f', -1, 64)
+		}
+	case "select":
+		if p.Select != nil {
+			return p.Select.Name
+		}
+	case "status":
+		if p.Status != nil {
+			return p.Status.Name
+		}
+	case "multi_select":
+		names := make([]string, len(p.MultiSelect))
+		for i, option := range p.MultiSelect {
+			names[i] = option.Name
+		}
+		return strings.Join(names, ", ")
+	case "date":
+		return formatDateValue(p.Date)
+	case "checkbox":
+		if p.Checkbox {
+			return "[x]"
+		}
+		return "[ ]"
+	case "url":
+		if p.URL != nil {
+			return *p.URL
+		}
+	case "email":
+		if p.Email != nil {
+			return *p.Email
+		}
+	case "phone_number":
+		if p.PhoneNumber != nil {
+			return *p.PhoneNumber
+		}
+	case "people":
+		names := make([]string, len(p.People))
+		for i, user := range p.People {
+			names[i] = user.Name
+			if names[i] == "" {
+				names[i] = user.ID
+			}
+		}
+		return strings.Join(names, ", ")
+	case "relation":
+		ids := make([]string, len(p.Relation))
+		for i, ref := range p.Relation {
+			ids[i] = ref.ID
+		}
+		return strings.Join(ids, ", ")
+	case "formula":
+		if p.Formula != nil {
+			return p.Formula.plainText()
+		}
+	case "unique_id":
+		if p.UniqueID != nil {
+			number := strconv.Itoa(p.UniqueID.Number)
+			if p.UniqueID.Prefix != nil {
+				return *p.UniqueID.Prefix + "-" + number
+			}
+			return number
+		}
+	case "created_time":
+		return p.CreatedTime
+	case "last_edited_time":
+		return p.LastEditedTime
+	}
+	return ""
+}
+
+// plainText renders a formula result as text
+func (f *FormulaValue) plainText() string {
+	switch f.Type {
+	case "string":
+		if f.String != nil {
+			return *f.String
+		}
+	case "number":
+		if f.Number != nil {
+			return strconv.FormatFloat(*f.Number, 'f', -1, 64)
+		}
+	case "boolean":
+		if f.Boolean != nil {
+			return strconv.FormatBool(*f.Boolean)
+		}
+	case "date":
+		return formatDateValue(f.Date)
+	}
+	return ""
+}
+
+// formatDateValue renders a date or date range
+func formatDateValue(date *DateValue) string {
+	if date == nil {
+		return ""
+	}
+	if date.End != nil && *date.End != "" {
+		return date.Start + " → " + *date.End
+	}
+	return date.Start
+}